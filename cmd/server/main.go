@@ -3,7 +3,13 @@ package main
 import (
 	"context"
 	"errors"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/oyamo/forumz-auth-server/internal/domain/audit"
+	"github.com/oyamo/forumz-auth-server/internal/domain/challenge"
 	"github.com/oyamo/forumz-auth-server/internal/domain/connections"
+	"github.com/oyamo/forumz-auth-server/internal/domain/invite"
+	"github.com/oyamo/forumz-auth-server/internal/domain/mfa"
+	"github.com/oyamo/forumz-auth-server/internal/domain/passkey"
 	"github.com/oyamo/forumz-auth-server/internal/domain/user"
 	"github.com/oyamo/forumz-auth-server/internal/infrastructure/persistance/postgres"
 	redis_cache "github.com/oyamo/forumz-auth-server/internal/infrastructure/persistance/redis-cache"
@@ -28,6 +34,10 @@ func main() {
 		logger.Fatal(err)
 	}
 
+	for version, key := range conf.PasswordPeppers {
+		pkg.RegisterPepper(version, key)
+	}
+
 	// Set up OpenTelemetry.
 	otelShutdown, err := pkg.SetupOTelSDK(context.Background())
 	if err != nil {
@@ -68,13 +78,71 @@ func main() {
 
 	personRepo := postgres.NewPersonRepository(conn)
 	redisPersonRepo := redis_cache.NewRedisPersonRepository(redisClient)
-	connectionRepo := postgres.NewConnectionRepository(conn)
-	connectionsUC := connections.NewUseCase(connectionRepo)
-	personsUC := user.NewUseCase(personRepo, redisPersonRepo, logger, conf, privateKey, publicKey)
+	auditRepo := postgres.NewAuditRepository(conn)
+	auditUC := audit.NewUseCase(auditRepo, logger)
+	connectionCounterRepo := redis_cache.NewRedisConnectionCounterRepository(redisClient)
+	connectionRepo := connections.NewCountingRepository(postgres.NewConnectionRepository(conn), connectionCounterRepo, logger)
+	connectionSettingsRepo := postgres.NewConnectionSettingsRepository(conn)
+	blockRepo := postgres.NewBlockRepository(conn)
+	mutualConnectionsRepo := redis_cache.NewRedisMutualConnectionsRepository(redisClient, conf.MutualConnectionsTTL)
+	suggestionRepo := postgres.NewSuggestionRepository(conn)
+	redisSuggestionRepo := redis_cache.NewRedisSuggestionRepository(redisClient, conf.SuggestionsTTL)
+	connectionsUC := connections.NewUseCase(connectionRepo, connectionSettingsRepo, blockRepo, mutualConnectionsRepo, suggestionRepo, redisSuggestionRepo, auditUC, conf, logger)
+	counterReconciler := connections.NewCounterReconciler(connectionRepo, connectionCounterRepo, conf, logger)
+	go counterReconciler.Run(context.Background())
+	suggestionWorker := connections.NewSuggestionWorker(connectionsUC, conf, logger)
+	go suggestionWorker.Run(context.Background())
+	inviteRepo := postgres.NewInviteRepository(conn)
+	inviteUC := invite.NewUseCase(inviteRepo, connectionsUC, logger, conf)
+	mfaRepo := postgres.NewMFARepository(conn)
+	mfaUC := mfa.NewUseCase(mfaRepo, conf)
+
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          conf.WebAuthnRPID,
+		RPDisplayName: conf.WebAuthnRPDisplayName,
+		RPOrigins:     conf.WebAuthnRPOrigins,
+	})
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	credentialRepo := postgres.NewCredentialRepository(conn)
+	webAuthnSessionRepo := redis_cache.NewRedisWebAuthnSessionRepository(redisClient)
+	passkeyUC := passkey.NewUseCase(credentialRepo, webAuthnSessionRepo, webAuthn)
+	magicLinkRepo := redis_cache.NewRedisMagicLinkRepository(redisClient)
+	loginAttemptRepo := redis_cache.NewRedisLoginAttemptRepository(redisClient)
+	loginGuard := user.NewLoginGuard(loginAttemptRepo, jsonSender, conf, logger)
+	rateLimiter := redis_cache.NewRedisRateLimiter(redisClient)
+	passwordHistoryRepo := postgres.NewPasswordHistoryRepository(conn)
+	passwordPolicy, err := user.NewPasswordPolicy(conf)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	registrationPolicy := user.NewRegistrationPolicy(conf, passwordPolicy)
+
+	var geoLocator user.GeoLocator
+	if conf.GeoIPFile != "" {
+		geoIPFile, err := pkg.NewGeoIPFile(conf.GeoIPFile)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		geoLocator = geoIPFile
+	}
+
+	knownDeviceRepo := postgres.NewKnownDeviceRepository(conn)
+	loginMonitor := user.NewLoginMonitor(knownDeviceRepo, geoLocator, jsonSender, conf, logger)
+	sessionRepo := redis_cache.NewRedisSessionRepository(redisClient)
+	challengeRepo := redis_cache.NewRedisChallengeRepository(redisClient)
+	challengeVerifier, err := challenge.NewVerifier(conf, challengeRepo)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	personsUC := user.NewUseCase(personRepo, redisPersonRepo, logger, conf, privateKey, publicKey, registrationPolicy, passwordPolicy, passwordHistoryRepo, inviteUC, mfaUC, passkeyUC, magicLinkRepo, loginGuard, auditUC, loginMonitor, sessionRepo, challengeVerifier)
 
 	tracer := otel.Tracer("forumz-auth-server")
-	router := web.NewRouter(logger, jsonSender, connectionsUC, personsUC, publicKey, tracer)
+	router := web.NewRouter(logger, jsonSender, connectionsUC, personsUC, inviteUC, mfaUC, passkeyUC, auditUC, publicKey, tracer, conf, rateLimiter, sessionRepo)
 	ginEngine := router.Setup()
+	internalEngine := router.SetupInternal()
 
-	logger.Fatal(ginEngine.Run(":3000"))
+	logger.Fatal(serve(conf, logger, ginEngine, internalEngine))
 }
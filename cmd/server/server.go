@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+const readHeaderTimeout = time.Second * 10
+
+// serve runs the configured listeners: plain HTTP and TLS for the public
+// routes, and mutual TLS for the internal ones. It returns when the first
+// listener fails.
+func serve(conf *pkg.Config, logger *zap.SugaredLogger, public, internal http.Handler) error {
+	var servers []func() error
+
+	if conf.HTTPAddr != "" {
+		server := &http.Server{
+			Addr:              conf.HTTPAddr,
+			Handler:           public,
+			ReadHeaderTimeout: readHeaderTimeout,
+		}
+		servers = append(servers, server.ListenAndServe)
+		logger.Infow("serving http", "addr", conf.HTTPAddr)
+	}
+
+	if conf.TLSAddr != "" || conf.MTLSAddr != "" {
+		reloader, err := pkg.NewCertificateReloader(conf.TLSCertFile, conf.TLSKeyFile, conf.P12Certificate, conf.CertPassword, conf.TLSReloadInterval, func(err error) {
+			logger.Errorw("error while reloading tls certificate", "error", err)
+		})
+		if err != nil {
+			return err
+		}
+
+		if conf.TLSAddr != "" {
+			server := &http.Server{
+				Addr:              conf.TLSAddr,
+				Handler:           public,
+				ReadHeaderTimeout: readHeaderTimeout,
+				TLSConfig: &tls.Config{
+					MinVersion:     tls.VersionTLS12,
+					GetCertificate: reloader.GetCertificate,
+				},
+			}
+			servers = append(servers, func() error { return server.ListenAndServeTLS("", "") })
+			logger.Infow("serving https", "addr", conf.TLSAddr)
+		}
+
+		if conf.MTLSAddr != "" {
+			clientCAs, err := pkg.LoadCertPool(conf.MTLSClientCAFile)
+			if err != nil {
+				return err
+			}
+
+			server := &http.Server{
+				Addr:              conf.MTLSAddr,
+				Handler:           internal,
+				ReadHeaderTimeout: readHeaderTimeout,
+				TLSConfig: &tls.Config{
+					MinVersion:     tls.VersionTLS12,
+					GetCertificate: reloader.GetCertificate,
+					ClientAuth:     tls.RequireAndVerifyClientCert,
+					ClientCAs:      clientCAs,
+				},
+			}
+			servers = append(servers, func() error { return server.ListenAndServeTLS("", "") })
+			logger.Infow("serving mutual tls", "addr", conf.MTLSAddr)
+		}
+	}
+
+	if len(servers) == 0 {
+		return errors.New("no listeners configured")
+	}
+
+	errs := make(chan error, len(servers))
+	for _, run := range servers {
+		go func(run func() error) {
+			errs <- run()
+		}(run)
+	}
+	return <-errs
+}
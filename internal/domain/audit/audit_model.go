@@ -0,0 +1,22 @@
+package audit
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Event is one entry of the audit log. ActorId is the person who performed
+// the action and SubjectId the person it was performed on; either is
+// uuid.Nil when unknown, e.g. the actor of a failed login.
+type Event struct {
+	Id              int64
+	ActorId         uuid.UUID
+	SubjectId       uuid.UUID
+	Action          string
+	IP              string
+	UserAgent       string
+	RequestId       uuid.UUID
+	Outcome         string
+	Details         map[string]any
+	DatetimeCreated time.Time
+}
@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Query filters the audit log. Zero values match everything. Results are
+// ordered newest first; pass the NextCursor of a page as Cursor to continue.
+type Query struct {
+	ActorId   uuid.UUID
+	SubjectId uuid.UUID
+	Action    string
+	Outcome   string
+	From      time.Time
+	To        time.Time
+	Cursor    int64
+	Limit     int
+}
+
+type EventItem struct {
+	Id              int64          `json:"id"`
+	ActorId         *uuid.UUID     `json:"actorId"`
+	SubjectId       *uuid.UUID     `json:"subjectId"`
+	Action          string         `json:"action"`
+	IP              string         `json:"ip"`
+	UserAgent       string         `json:"userAgent"`
+	RequestId       *uuid.UUID     `json:"requestId"`
+	Outcome         string         `json:"outcome"`
+	Details         map[string]any `json:"details,omitempty"`
+	DatetimeCreated time.Time      `json:"datetimeCreated"`
+}
+
+type EventPage struct {
+	Events     []EventItem `json:"events"`
+	NextCursor int64       `json:"nextCursor,omitempty"`
+}
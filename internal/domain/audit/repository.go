@@ -0,0 +1,10 @@
+package audit
+
+import "context"
+
+// Repository is append-only: events are never updated or deleted.
+type Repository interface {
+	Append(ctx context.Context, event *Event) error
+	// Query returns up to q.Limit events matching q, newest first.
+	Query(ctx context.Context, q *Query) ([]EventItem, error)
+}
@@ -0,0 +1,169 @@
+package audit
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"strings"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionLogin          = "login"
+	ActionRegister       = "register"
+	ActionProfileUpdate  = "profile.update"
+	ActionPasswordChange = "password.change"
+	ActionPasswordReset  = "password.reset"
+	ActionConnect        = "connection.create"
+	ActionDisconnect     = "connection.delete"
+	ActionConnectAccept  = "connection.accept"
+	ActionConnectReject  = "connection.reject"
+	ActionConnectCancel  = "connection.cancel"
+	ActionBlock          = "block.create"
+	ActionUnblock        = "block.delete"
+	ActionAuditQuery     = "admin.audit.query"
+	ActionSessionsRevoke = "sessions.revoke"
+)
+
+// Column sizes of the audit_event table. Longer client values are cut rather
+// than failing the insert, which would keep the event out of the log.
+const (
+	maxIPLength        = 64
+	maxUserAgentLength = 512
+)
+
+const (
+	OutcomeSuccess     = "success"
+	OutcomeFailure     = "failure"
+	OutcomeMFARequired = "mfa_required"
+)
+
+// Context keys under which the web layer stores client details. Use cases
+// pass their context to Record, which copies them into the event.
+const (
+	ContextKeyRequestId = "id"
+	ContextKeyClientIP  = "clientIP"
+	ContextKeyUserAgent = "userAgent"
+	// ContextKeyPrincipal holds the service principal of mutual TLS callers.
+	ContextKeyPrincipal = "principal"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 200
+)
+
+type UseCase struct {
+	repo   Repository
+	logger *zap.SugaredLogger
+}
+
+// Record appends event to the audit log, filling in the request id, client
+// IP and user agent from ctx. Failures are logged only so that auditing
+// never breaks the action being audited.
+func (uc *UseCase) Record(ctx context.Context, event *Event) {
+	if requestId, ok := ctx.Value(ContextKeyRequestId).(uuid.UUID); ok {
+		event.RequestId = requestId
+	}
+	ip, userAgent := Client(ctx)
+	event.IP = truncate(ip, maxIPLength)
+	event.UserAgent = truncate(userAgent, maxUserAgentLength)
+	if principal, ok := ctx.Value(ContextKeyPrincipal).(string); ok {
+		if event.Details == nil {
+			event.Details = make(map[string]any)
+		}
+		event.Details["principal"] = principal
+	}
+
+	err := uc.repo.Append(ctx, event)
+	if err != nil {
+		uc.logger.Errorw("error while recording audit event", "action", event.Action, "error", err)
+	}
+}
+
+// truncate cuts s to at most n bytes of valid UTF-8.
+func truncate(s string, n int) string {
+	s = strings.ToValidUTF8(s, "")
+	if len(s) > n {
+		// Cutting may split the last rune, which is dropped.
+		s = strings.ToValidUTF8(s[:n], "")
+	}
+	return s
+}
+
+// Client returns the client IP and user agent the web layer stored in ctx.
+func Client(ctx context.Context) (ip, userAgent string) {
+	ip, _ = ctx.Value(ContextKeyClientIP).(string)
+	userAgent, _ = ctx.Value(ContextKeyUserAgent).(string)
+	return ip, userAgent
+}
+
+// Outcome maps the error returned by an audited action to its outcome.
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
+
+// Query returns a page of audit events for an administrator and records the
+// query itself. adminId is uuid.Nil for service principals, which Record
+// takes from ctx.
+func (uc *UseCase) Query(ctx context.Context, adminId uuid.UUID, q *Query) (*EventPage, error) {
+	page, err := uc.query(ctx, q)
+
+	uc.Record(ctx, &Event{
+		ActorId: adminId,
+		Action:  ActionAuditQuery,
+		Outcome: Outcome(err),
+		Details: map[string]any{
+			"actorId":   q.ActorId,
+			"subjectId": q.SubjectId,
+			"action":    q.Action,
+			"outcome":   q.Outcome,
+		},
+	})
+
+	return page, err
+}
+
+// LoginHistory returns a page of login attempts against personId's account.
+func (uc *UseCase) LoginHistory(ctx context.Context, personId uuid.UUID, cursor int64, limit int) (*EventPage, error) {
+	return uc.query(ctx, &Query{
+		SubjectId: personId,
+		Action:    ActionLogin,
+		Cursor:    cursor,
+		Limit:     limit,
+	})
+}
+
+func (uc *UseCase) query(ctx context.Context, q *Query) (*EventPage, error) {
+	if q.Limit <= 0 {
+		q.Limit = defaultLimit
+	}
+	if q.Limit > maxLimit {
+		q.Limit = maxLimit
+	}
+
+	events, err := uc.repo.Query(ctx, q)
+	if err != nil {
+		uc.logger.Errorw("error while querying audit events", "error", err)
+		return nil, err
+	}
+
+	page := &EventPage{Events: events}
+	if page.Events == nil {
+		page.Events = []EventItem{}
+	}
+	if len(events) == q.Limit {
+		page.NextCursor = events[len(events)-1].Id
+	}
+	return page, nil
+}
+
+func NewUseCase(repo Repository, logger *zap.SugaredLogger) *UseCase {
+	return &UseCase{
+		repo:   repo,
+		logger: logger,
+	}
+}
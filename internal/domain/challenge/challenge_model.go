@@ -0,0 +1,17 @@
+package challenge
+
+import "time"
+
+// Challenge tells a client what it has to solve before retrying. Provider
+// selects the widget or solver; the other fields depend on it.
+type Challenge struct {
+	Provider string `json:"provider"`
+	// SiteKey is the public key of hCaptcha and Turnstile widgets.
+	SiteKey string `json:"siteKey,omitempty"`
+	// Id and Difficulty describe a proof of work: find a nonce such that
+	// SHA-256 of "<id>:<nonce>" starts with Difficulty zero bits, and answer
+	// with "<id>:<nonce>".
+	Id         string    `json:"id,omitempty"`
+	Difficulty int       `json:"difficulty,omitempty"`
+	ExpiresAt  time.Time `json:"expiresAt,omitempty"`
+}
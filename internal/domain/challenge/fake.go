@@ -0,0 +1,33 @@
+package challenge
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+)
+
+// Fake accepts a fixed answer. It lets local setups and end to end tests
+// exercise the challenge flow without solving real challenges; never use it
+// in production.
+type Fake struct {
+	answer string
+}
+
+func (f *Fake) Issue(ctx context.Context) (*Challenge, error) {
+	return &Challenge{
+		Provider: ProviderFake,
+	}, nil
+}
+
+func (f *Fake) Verify(ctx context.Context, response, remoteIP string) error {
+	if f.answer == "" || subtle.ConstantTimeCompare([]byte(response), []byte(f.answer)) != 1 {
+		return fmt.Errorf("%w: wrong answer", ErrChallengeFailed)
+	}
+	return nil
+}
+
+func NewFake(answer string) *Fake {
+	return &Fake{
+		answer: answer,
+	}
+}
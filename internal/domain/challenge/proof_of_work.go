@@ -0,0 +1,89 @@
+package challenge
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"strings"
+	"time"
+)
+
+const (
+	proofOfWorkTTL   = time.Minute * 5
+	maxNonceLength   = 64
+	maxDifficulty    = 32
+	challengeIdBytes = 16
+)
+
+// ProofOfWork is a hashcash style challenge that needs no external service.
+// Solving one costs the client about 2^difficulty hashes, checking it costs
+// the server one.
+type ProofOfWork struct {
+	repo       Repository
+	difficulty int
+}
+
+func (p *ProofOfWork) Issue(ctx context.Context) (*Challenge, error) {
+	b := make([]byte, challengeIdBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+
+	id := hex.EncodeToString(b)
+	err = p.repo.Save(ctx, id, proofOfWorkTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Challenge{
+		Provider:   ProviderProofOfWork,
+		Id:         id,
+		Difficulty: p.difficulty,
+		ExpiresAt:  time.Now().Add(proofOfWorkTTL),
+	}, nil
+}
+
+// Verify checks a "<id>:<nonce>" answer. The work is checked before the
+// challenge is consumed, so garbage answers cannot burn valid challenges.
+func (p *ProofOfWork) Verify(ctx context.Context, response, remoteIP string) error {
+	id, nonce, ok := strings.Cut(response, ":")
+	if !ok || len(id) != 2*challengeIdBytes || nonce == "" || len(nonce) > maxNonceLength {
+		return fmt.Errorf("%w: malformed proof of work", ErrChallengeFailed)
+	}
+
+	if LeadingZeroBits(sha256.Sum256([]byte(response))) < p.difficulty {
+		return fmt.Errorf("%w: insufficient proof of work", ErrChallengeFailed)
+	}
+
+	ok, err := p.repo.Consume(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: unknown, expired or already used challenge", ErrChallengeFailed)
+	}
+	return nil
+}
+
+// LeadingZeroBits counts the zero bits at the start of sum.
+func LeadingZeroBits(sum [sha256.Size]byte) int {
+	n := 0
+	for _, b := range sum {
+		n += bits.LeadingZeros8(b)
+		if b != 0 {
+			break
+		}
+	}
+	return n
+}
+
+func NewProofOfWork(repo Repository, difficulty int) *ProofOfWork {
+	return &ProofOfWork{
+		repo:       repo,
+		difficulty: min(max(difficulty, 1), maxDifficulty),
+	}
+}
@@ -0,0 +1,14 @@
+package challenge
+
+import (
+	"context"
+	"time"
+)
+
+// Repository tracks issued proof of work challenges so that each one can be
+// solved only once.
+type Repository interface {
+	Save(ctx context.Context, id string, ttl time.Duration) error
+	// Consume removes id, returning false when it was unknown or expired.
+	Consume(ctx context.Context, id string) (bool, error)
+}
@@ -0,0 +1,53 @@
+package challenge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
+)
+
+// Challenge providers accepted in AUTH_SERVICE_CHALLENGE_PROVIDER.
+const (
+	ProviderNone        = "none"
+	ProviderProofOfWork = "pow"
+	ProviderHCaptcha    = "hcaptcha"
+	ProviderTurnstile   = "turnstile"
+	ProviderFake        = "fake"
+)
+
+var ErrChallengeFailed = errors.New("challenge failed")
+
+// Verifier issues challenges and checks the answers of clients. Verify
+// returns an error matching ErrChallengeFailed when response is wrong,
+// expired or already used.
+type Verifier interface {
+	Issue(ctx context.Context) (*Challenge, error)
+	Verify(ctx context.Context, response, remoteIP string) error
+}
+
+// NewVerifier builds the verifier configured by conf.ChallengeProvider. It
+// returns nil when challenges are disabled.
+func NewVerifier(conf *pkg.Config, repo Repository) (Verifier, error) {
+	switch conf.ChallengeProvider {
+	case ProviderNone:
+		return nil, nil
+	case ProviderProofOfWork:
+		return NewProofOfWork(repo, conf.ChallengeDifficulty), nil
+	case ProviderHCaptcha, ProviderTurnstile:
+		if conf.ChallengeSiteKey == "" || conf.ChallengeSecret == "" {
+			return nil, fmt.Errorf("challenge provider %s needs a site key and a secret", conf.ChallengeProvider)
+		}
+		if conf.ChallengeProvider == ProviderHCaptcha {
+			return NewHCaptcha(conf.ChallengeSiteKey, conf.ChallengeSecret), nil
+		}
+		return NewTurnstile(conf.ChallengeSiteKey, conf.ChallengeSecret), nil
+	case ProviderFake:
+		if conf.ChallengeSecret == "" {
+			return nil, errors.New("challenge provider fake needs a secret, the answer it accepts")
+		}
+		return NewFake(conf.ChallengeSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown challenge provider %q", conf.ChallengeProvider)
+	}
+}
@@ -0,0 +1,14 @@
+package connections
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Block stops BlockedId from connecting to or viewing BlockerId, and the
+// other way round.
+type Block struct {
+	BlockerId       uuid.UUID `json:"blockerId"`
+	BlockedId       uuid.UUID `json:"blockedId"`
+	DatetimeCreated time.Time `json:"datetimeCreated"`
+}
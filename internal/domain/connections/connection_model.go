@@ -5,8 +5,35 @@ import (
 	"time"
 )
 
+// Statuses of a connection. Connections start out pending only when the
+// person connected to approves requests themselves.
+const (
+	StatusPending  = "pending"
+	StatusAccepted = "accepted"
+	StatusRejected = "rejected"
+)
+
 type Connection struct {
 	UserId          uuid.UUID
 	ConnectedTo     uuid.UUID
+	Status          string
 	DatetimeCreated time.Time
 }
+
+// Settings are the connection preferences of a person.
+type Settings struct {
+	PersonId     uuid.UUID `json:"-"`
+	AutoAccept   bool      `json:"autoAccept"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// Counts are the numbers of accepted connections to and from a person.
+type Counts struct {
+	Followers int64 `json:"followers"`
+	Following int64 `json:"following"`
+}
+
+type PersonCounts struct {
+	PersonId uuid.UUID
+	Counts
+}
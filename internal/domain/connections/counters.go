@@ -0,0 +1,248 @@
+package connections
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	// reconcileBatchSize is how many people are counted per query.
+	reconcileBatchSize = 500
+	counterSourceCache = "cache"
+	counterSourceDB    = "database"
+)
+
+var (
+	counterReads = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "connection_counter_reads_total",
+			Help: "Connection counter reads by source",
+		},
+		[]string{"source"},
+	)
+
+	counterCorrections = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "connection_counter_corrections_total",
+			Help: "Cached connection counters corrected by reconciliation",
+		},
+		[]string{"counter"},
+	)
+
+	counterDrift = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "connection_counter_drift_total",
+			Help: "Absolute difference corrected by reconciliation",
+		},
+		[]string{"counter"},
+	)
+
+	counterReconciliations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "connection_counter_reconciliations_total",
+			Help: "Counter reconciliation runs by result",
+		},
+		[]string{"result"},
+	)
+)
+
+// countingRepository keeps the counters in step with the connections saved,
+// accepted and deleted through it, and serves Count from them. Counter errors
+// are logged rather than returned, reconciliation corrects what they leave
+// off.
+type countingRepository struct {
+	Repository
+	counters CounterRepository
+	logger   *zap.SugaredLogger
+}
+
+func (r *countingRepository) Save(ctx context.Context, connection *Connection) (bool, error) {
+	changed, err := r.Repository.Save(ctx, connection)
+	// A changed connection that is now accepted was created accepted or has
+	// just been accepted, either way it is new to the counters.
+	accepted := changed && connection.Status == StatusAccepted
+	if err == nil && accepted {
+		r.add(ctx, connection.UserId, connection.ConnectedTo, 1)
+	}
+	return changed, err
+}
+
+func (r *countingRepository) Delete(ctx context.Context, id, connectedTo uuid.UUID) (bool, error) {
+	accepted, err := r.Repository.Delete(ctx, id, connectedTo)
+	if err == nil && accepted {
+		r.add(ctx, id, connectedTo, -1)
+	}
+	return accepted, err
+}
+
+func (r *countingRepository) DeleteAccepted(ctx context.Context, id, connectedTo uuid.UUID) error {
+	err := r.Repository.DeleteAccepted(ctx, id, connectedTo)
+	if err == nil {
+		r.add(ctx, id, connectedTo, -1)
+	}
+	return err
+}
+
+func (r *countingRepository) Accept(ctx context.Context, id, connectedTo uuid.UUID) error {
+	err := r.Repository.Accept(ctx, id, connectedTo)
+	if err == nil {
+		r.add(ctx, id, connectedTo, 1)
+	}
+	return err
+}
+
+func (r *countingRepository) Count(ctx context.Context, id uuid.UUID) (*Counts, error) {
+	counts, err := r.counters.Get(ctx, id)
+	if err != nil {
+		r.logger.Errorw("error while reading connection counters", "error", err)
+	}
+	if counts != nil {
+		counterReads.WithLabelValues(counterSourceCache).Inc()
+		return counts, nil
+	}
+
+	counterReads.WithLabelValues(counterSourceDB).Inc()
+	counts, err = r.Repository.Count(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.counters.Set(ctx, id, counts)
+	if err != nil {
+		r.logger.Errorw("error while caching connection counters", "error", err)
+	}
+	return counts, nil
+}
+
+func (r *countingRepository) add(ctx context.Context, follower, followed uuid.UUID, delta int64) {
+	err := r.counters.Add(ctx, follower, followed, delta)
+	if err != nil {
+		r.logger.Errorw("error while updating connection counters", "error", err)
+	}
+}
+
+// NewCountingRepository wraps next so that the counters follow its
+// connections.
+func NewCountingRepository(next Repository, counters CounterRepository, logger *zap.SugaredLogger) Repository {
+	return &countingRepository{
+		Repository: next,
+		counters:   counters,
+		logger:     logger,
+	}
+}
+
+// CounterReconciler periodically counts the connections of everyone in the
+// database and corrects the cached counters that drifted, after a failed
+// counter update or a connection changed outside the service.
+type CounterReconciler struct {
+	connectionRepository Repository
+	counters             CounterRepository
+	interval             time.Duration
+	logger               *zap.SugaredLogger
+}
+
+// Run reconciles every interval until ctx is done. A zero interval disables
+// reconciliation.
+func (r *CounterReconciler) Run(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		leased, err := r.counters.Lease(ctx, r.interval)
+		if err != nil {
+			r.logger.Errorw("error while leasing counter reconciliation", "error", err)
+			continue
+		}
+		if !leased {
+			continue
+		}
+
+		err = r.Reconcile(ctx)
+		if err != nil {
+			counterReconciliations.WithLabelValues("error").Inc()
+			r.logger.Errorw("error while reconciling connection counters", "error", err)
+			continue
+		}
+		counterReconciliations.WithLabelValues("success").Inc()
+	}
+}
+
+// Reconcile corrects the counters of everyone whose counts are cached.
+func (r *CounterReconciler) Reconcile(ctx context.Context) error {
+	after := uuid.Nil
+	for {
+		batch, err := r.connectionRepository.CountBatch(ctx, after, reconcileBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for i := range batch {
+			err = r.correct(ctx, &batch[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(batch) < reconcileBatchSize {
+			return nil
+		}
+		after = batch[len(batch)-1].PersonId
+	}
+}
+
+func (r *CounterReconciler) correct(ctx context.Context, counts *PersonCounts) error {
+	previous, err := r.counters.Replace(ctx, counts.PersonId, &counts.Counts)
+	if err != nil {
+		return err
+	}
+	if previous == nil || *previous == counts.Counts {
+		return nil
+	}
+
+	drift := map[string]int64{
+		"followers": counts.Followers - previous.Followers,
+		"following": counts.Following - previous.Following,
+	}
+	for counter, diff := range drift {
+		if diff == 0 {
+			continue
+		}
+		if diff < 0 {
+			diff = -diff
+		}
+		counterCorrections.WithLabelValues(counter).Inc()
+		counterDrift.WithLabelValues(counter).Add(float64(diff))
+	}
+
+	r.logger.Infow("corrected connection counters",
+		"personId", counts.PersonId,
+		"followers", counts.Followers,
+		"following", counts.Following,
+		"cachedFollowers", previous.Followers,
+		"cachedFollowing", previous.Following,
+	)
+	return nil
+}
+
+func NewCounterReconciler(connectionRepository Repository, counters CounterRepository, conf *pkg.Config, logger *zap.SugaredLogger) *CounterReconciler {
+	return &CounterReconciler{
+		connectionRepository: connectionRepository,
+		counters:             counters,
+		interval:             conf.CounterReconcileInterval,
+		logger:               logger,
+	}
+}
@@ -0,0 +1,78 @@
+package connections
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Orders of connection listings.
+const (
+	SortRecent = "recent"
+	SortName   = "name"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListQuery pages a connection listing. Listings sorted by SortRecent are
+// ordered newest first, by SortName alphabetically. Prefix keeps the people
+// whose first or last name starts with it, ignoring case.
+type ListQuery struct {
+	Sort   string
+	Prefix string
+	Cursor *Cursor
+	Limit  int
+}
+
+// Cursor is the sort key of the last item of a page; the next page starts
+// right after it. Only the fields of the listing's order are set, Sort
+// records which order that is.
+type Cursor struct {
+	Sort            string    `json:"s"`
+	DatetimeCreated time.Time `json:"t"`
+	FirstName       string    `json:"f,omitempty"`
+	LastName        string    `json:"l,omitempty"`
+	Id              uuid.UUID `json:"i"`
+}
+
+// cursorAfter returns the cursor continuing after item in a listing ordered
+// by sort.
+func cursorAfter(item *ConnectionsItem, sort string) (*Cursor, error) {
+	id, err := uuid.Parse(item.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := &Cursor{Sort: sort, Id: id}
+	if sort == SortName {
+		cursor.FirstName, cursor.LastName = item.FirstName, item.LastName
+	} else {
+		cursor.DatetimeCreated = item.DatetimeCreated
+	}
+	return cursor, nil
+}
+
+// EncodeCursor returns cursor as an opaque URL safe string.
+func EncodeCursor(cursor *Cursor) (string, error) {
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func DecodeCursor(encoded string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	err = json.Unmarshal(b, &cursor)
+	if err != nil || cursor.Id == uuid.Nil || (cursor.Sort != SortRecent && cursor.Sort != SortName) {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
@@ -14,5 +14,32 @@ type ConnectionsItem struct {
 	FirstName       string    `json:"firstName"`
 	LastName        string    `json:"lastName"`
 	Id              string    `json:"id"`
+	Status          string    `json:"status,omitempty"`
 	DatetimeCreated time.Time `json:"datetimeCreated"`
 }
+
+type ConnectResponse struct {
+	Status string `json:"status"`
+}
+
+type UpdateSettingsDTO struct {
+	PersonId   uuid.UUID `json:"-"`
+	AutoAccept *bool     `json:"autoAccept" validate:"required"`
+}
+
+// MutualConnections is a page of the people two persons are both connected
+// to, ordered by id. Pass NextCursor as the cursor to continue.
+type MutualConnections struct {
+	Count       int               `json:"count"`
+	Connections []ConnectionsItem `json:"connections"`
+	NextCursor  *uuid.UUID        `json:"nextCursor,omitempty"`
+}
+
+// Suggestion is a person the caller may know, with the number of people the
+// caller is connected to who are connected to them.
+type Suggestion struct {
+	FirstName         string `json:"firstName"`
+	LastName          string `json:"lastName"`
+	Id                string `json:"id"`
+	MutualConnections int    `json:"mutualConnections"`
+}
@@ -3,10 +3,109 @@ package connections
 import (
 	"context"
 	"github.com/google/uuid"
+	"time"
 )
 
 type Repository interface {
-	Save(ctx context.Context, connection *Connection) error
-	Find(ctx context.Context, id uuid.UUID) ([]ConnectionsItem, error)
-	Delete(ctx context.Context, id, connectedTo uuid.UUID) error
+	// Save stores connection unless it exists already, and sets its Status to
+	// the stored one. A pending connection saved again as accepted is
+	// accepted. It reports whether the connection was created or changed.
+	Save(ctx context.Context, connection *Connection) (bool, error)
+	// Find returns a page of the accepted connections of id, see ListQuery.
+	Find(ctx context.Context, id uuid.UUID, q *ListQuery) ([]ConnectionsItem, error)
+	// FindInbound returns a page of the people with an accepted connection to
+	// id.
+	FindInbound(ctx context.Context, id uuid.UUID, q *ListQuery) ([]ConnectionsItem, error)
+	Count(ctx context.Context, id uuid.UUID) (*Counts, error)
+	// CountBatch counts the connections of up to limit people with an id
+	// greater than after, ordered by id.
+	CountBatch(ctx context.Context, after uuid.UUID, limit int) ([]PersonCounts, error)
+	// Delete removes the connection or request and reports whether it was an
+	// accepted connection.
+	Delete(ctx context.Context, id, connectedTo uuid.UUID) (bool, error)
+	// DeleteAccepted removes an accepted connection and returns
+	// ErrConnectionNotFound when there is none, leaving requests alone.
+	DeleteAccepted(ctx context.Context, id, connectedTo uuid.UUID) error
+	// FindIncoming returns the pending requests to connect to id.
+	FindIncoming(ctx context.Context, id uuid.UUID) ([]ConnectionsItem, error)
+	// FindOutgoing returns the pending and rejected requests id made.
+	FindOutgoing(ctx context.Context, id uuid.UUID) ([]ConnectionsItem, error)
+	// Accept accepts a pending or rejected request, Reject rejects a pending
+	// one and DeletePending withdraws a pending one. They return
+	// ErrRequestNotFound when there is no such request.
+	Accept(ctx context.Context, id, connectedTo uuid.UUID) error
+	Reject(ctx context.Context, id, connectedTo uuid.UUID) error
+	DeletePending(ctx context.Context, id, connectedTo uuid.UUID) error
+	// FindMutual returns up to limit of the people both id and otherId are
+	// connected to with an id greater than after, ordered by id. People
+	// blocked by or blocking either of them are left out.
+	FindMutual(ctx context.Context, id, otherId, after uuid.UUID, limit int) ([]ConnectionsItem, error)
+	CountMutual(ctx context.Context, id, otherId uuid.UUID) (int, error)
+}
+
+type SettingsRepository interface {
+	// Find returns the settings of personId, or the defaults when they never
+	// changed them.
+	Find(ctx context.Context, personId uuid.UUID) (*Settings, error)
+	Save(ctx context.Context, settings *Settings) error
+}
+
+type BlockRepository interface {
+	Save(ctx context.Context, block *Block) error
+	// Delete returns ErrBlockNotFound when blockerId did not block blockedId.
+	Delete(ctx context.Context, blockerId, blockedId uuid.UUID) error
+	// Find returns the people blockerId blocked, newest first.
+	Find(ctx context.Context, blockerId uuid.UUID) ([]ConnectionsItem, error)
+	// Blocked reports whether either person blocked the other.
+	Blocked(ctx context.Context, id, otherId uuid.UUID) (bool, error)
+}
+
+// MutualConnectionsCache keeps pages of mutual connections. Mutual connections
+// are symmetric, so id and otherId may come in either order. Find returns nil
+// on a miss.
+type MutualConnectionsCache interface {
+	Find(ctx context.Context, id, otherId, after uuid.UUID, limit int) (*MutualConnections, error)
+	Save(ctx context.Context, id, otherId, after uuid.UUID, limit int, page *MutualConnections) error
+	// Invalidate drops every page involving one of ids.
+	Invalidate(ctx context.Context, ids ...uuid.UUID) error
+}
+
+// CounterRepository keeps the numbers of followers and followed people, so
+// profiles do not count connections on every view. Get returns nil on a miss.
+type CounterRepository interface {
+	Get(ctx context.Context, id uuid.UUID) (*Counts, error)
+	Set(ctx context.Context, id uuid.UUID, counts *Counts) error
+	// Add adds delta to the following count of follower and the followers
+	// count of followed, for the people whose counts are kept.
+	Add(ctx context.Context, follower, followed uuid.UUID, delta int64) error
+	// Replace replaces the kept counts of id and returns the previous ones,
+	// or nil when they are not kept.
+	Replace(ctx context.Context, id uuid.UUID, counts *Counts) (*Counts, error)
+	// Lease takes the reconciliation lease for ttl and reports whether it was
+	// free, so a single instance reconciles at a time.
+	Lease(ctx context.Context, ttl time.Duration) (bool, error)
+}
+
+type SuggestionRepository interface {
+	// Compute ranks up to limit of the people connected to the connections of
+	// id by how many of them they share. People id is connected to or asked
+	// to connect to, people blocked either way, dismissed people and id
+	// itself are left out.
+	Compute(ctx context.Context, id uuid.UUID, limit int) ([]Suggestion, error)
+	// FindPersons returns up to limit of the people with accepted connections
+	// and an id greater than after, ordered by id. Nobody else has
+	// suggestions.
+	FindPersons(ctx context.Context, after uuid.UUID, limit int) ([]uuid.UUID, error)
+	Dismiss(ctx context.Context, personId, suggestedId uuid.UUID) error
+}
+
+// SuggestionCache keeps the computed suggestions of each person. Find returns
+// nil on a miss.
+type SuggestionCache interface {
+	Find(ctx context.Context, id uuid.UUID) ([]Suggestion, error)
+	Save(ctx context.Context, id uuid.UUID, suggestions []Suggestion) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	// Lease takes the computation lease for ttl and reports whether it was
+	// free, so a single instance computes at a time.
+	Lease(ctx context.Context, ttl time.Duration) (bool, error)
 }
@@ -0,0 +1,148 @@
+package connections
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	// suggestionsBatchSize is how many people the worker lists per query.
+	suggestionsBatchSize = 500
+	// maxSuggestions is how many suggestions are kept per person.
+	maxSuggestions = maxLimit
+)
+
+// Suggestions returns up to limit of the people id may know, those sharing
+// the most connections with id first. They are served from the cache the
+// worker fills, and computed on a miss.
+func (uc *UseCase) Suggestions(ctx context.Context, id uuid.UUID, limit int) ([]Suggestion, error) {
+	limit = clampLimit(limit)
+
+	suggestions, err := uc.suggestionCache.Find(ctx, id)
+	if err != nil {
+		uc.logger.Errorw("error while reading cached suggestions", "error", err)
+	}
+	if suggestions == nil {
+		suggestions, err = uc.computeSuggestions(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
+
+// DismissSuggestion stops suggesting suggestedId to personId.
+func (uc *UseCase) DismissSuggestion(ctx context.Context, personId, suggestedId uuid.UUID) error {
+	err := uc.suggestionRepository.Dismiss(ctx, personId, suggestedId)
+	if err != nil {
+		return err
+	}
+
+	uc.invalidateSuggestions(ctx, personId)
+	return nil
+}
+
+func (uc *UseCase) computeSuggestions(ctx context.Context, id uuid.UUID) ([]Suggestion, error) {
+	suggestions, err := uc.suggestionRepository.Compute(ctx, id, maxSuggestions)
+	if err != nil {
+		return nil, err
+	}
+	if suggestions == nil {
+		suggestions = []Suggestion{}
+	}
+
+	err = uc.suggestionCache.Save(ctx, id, suggestions)
+	if err != nil {
+		uc.logger.Errorw("error while caching suggestions", "error", err)
+	}
+	return suggestions, nil
+}
+
+// invalidateSuggestions drops the cached suggestions of ids after their
+// connections changed, so they are computed again on the next read.
+func (uc *UseCase) invalidateSuggestions(ctx context.Context, ids ...uuid.UUID) {
+	for _, id := range ids {
+		err := uc.suggestionCache.Delete(ctx, id)
+		if err != nil {
+			uc.logger.Errorw("error while invalidating suggestions", "error", err)
+		}
+	}
+}
+
+// SuggestionWorker periodically computes the suggestions of everyone with
+// connections, so reads rarely compute them.
+type SuggestionWorker struct {
+	useCase  *UseCase
+	interval time.Duration
+	logger   *zap.SugaredLogger
+}
+
+// Run computes suggestions every interval until ctx is done. A zero interval
+// disables the worker, suggestions are then computed on reads.
+func (w *SuggestionWorker) Run(ctx context.Context) {
+	if w.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		leased, err := w.useCase.suggestionCache.Lease(ctx, w.interval)
+		if err != nil {
+			w.logger.Errorw("error while leasing suggestion computation", "error", err)
+			continue
+		}
+		if !leased {
+			continue
+		}
+
+		err = w.Compute(ctx)
+		if err != nil {
+			w.logger.Errorw("error while computing suggestions", "error", err)
+		}
+	}
+}
+
+// Compute computes and caches the suggestions of everyone with connections.
+func (w *SuggestionWorker) Compute(ctx context.Context) error {
+	after := uuid.Nil
+	for {
+		ids, err := w.useCase.suggestionRepository.FindPersons(ctx, after, suggestionsBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			_, err = w.useCase.computeSuggestions(ctx, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(ids) < suggestionsBatchSize {
+			return nil
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+func NewSuggestionWorker(useCase *UseCase, conf *pkg.Config, logger *zap.SugaredLogger) *SuggestionWorker {
+	return &SuggestionWorker{
+		useCase:  useCase,
+		interval: conf.SuggestionsInterval,
+		logger:   logger,
+	}
+}
@@ -2,44 +2,367 @@ package connections
 
 import (
 	"context"
+	"errors"
 	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/audit"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+	// maxPrefixLength is the longest name in characters, see the person
+	// table.
+	maxPrefixLength = 32
+)
+
+var (
+	ErrConnectionNotFound = errors.New("connection not found")
+	ErrRequestNotFound    = errors.New("connection request not found")
+	ErrRequestRejected    = errors.New("connection request was rejected")
+	ErrBlocked            = errors.New("person is blocked")
+	ErrBlockNotFound      = errors.New("person is not blocked")
+	ErrInvalidSort        = errors.New("invalid sort, expected recent or name")
 )
 
 type UseCase struct {
 	connectionRepository Repository
+	settingsRepository   SettingsRepository
+	blockRepository      BlockRepository
+	mutualCache          MutualConnectionsCache
+	suggestionRepository SuggestionRepository
+	suggestionCache      SuggestionCache
+	audit                *audit.UseCase
+	conf                 *pkg.Config
+	logger               *zap.SugaredLogger
+}
+
+// Connect connects dto.UserId to dto.ConnectionTo and returns the status of
+// the connection, and whether this call created or accepted it. With
+// connection requests enabled it stays pending until the other person
+// accepts it, unless they auto-accept requests.
+func (uc *UseCase) Connect(ctx context.Context, dto *CreateConnectionDTO) (string, bool, error) {
+	status := StatusAccepted
+	if uc.conf.ConnectionRequests {
+		settings, err := uc.settingsRepository.Find(ctx, dto.ConnectionTo)
+		if err != nil {
+			uc.record(ctx, audit.ActionConnect, dto.UserId, dto.ConnectionTo, err)
+			return "", false, err
+		}
+		if !settings.AutoAccept {
+			status = StatusPending
+		}
+	}
+
+	return uc.connect(ctx, dto, status)
 }
 
-func (uc *UseCase) Connect(ctx context.Context, dto *CreateConnectionDTO) error {
+// ConnectAccepted connects dto.UserId to dto.ConnectionTo without asking, for
+// connections both people already agreed to, like redeemed invites.
+func (uc *UseCase) ConnectAccepted(ctx context.Context, dto *CreateConnectionDTO) error {
+	_, _, err := uc.connect(ctx, dto, StatusAccepted)
+	return err
+}
+
+func (uc *UseCase) connect(ctx context.Context, dto *CreateConnectionDTO, status string) (string, bool, error) {
 	connection := &Connection{
 		UserId:      dto.UserId,
 		ConnectedTo: dto.ConnectionTo,
+		Status:      status,
+	}
+
+	blocked, err := uc.blockRepository.Blocked(ctx, dto.UserId, dto.ConnectionTo)
+	if err == nil && blocked {
+		err = ErrBlocked
 	}
-	err := uc.connectionRepository.Save(ctx, connection)
+	changed := false
+	if err == nil {
+		changed, err = uc.connectionRepository.Save(ctx, connection)
+	}
+	if err == nil && connection.Status == StatusRejected {
+		err = ErrRequestRejected
+	}
+	uc.record(ctx, audit.ActionConnect, dto.UserId, dto.ConnectionTo, err)
+	if err != nil {
+		return "", false, err
+	}
+
+	if changed {
+		uc.invalidateMutual(ctx, dto.UserId)
+		uc.invalidateSuggestions(ctx, dto.UserId)
+	}
+	return connection.Status, changed, nil
+}
+
+// Disconnect removes the accepted connection of dto.UserId to
+// dto.ConnectionTo. Requests are withdrawn with Cancel instead, and rejected
+// ones stay, so that the requester cannot ask again.
+func (uc *UseCase) Disconnect(ctx context.Context, dto *CreateConnectionDTO) error {
+	err := uc.connectionRepository.DeleteAccepted(ctx, dto.UserId, dto.ConnectionTo)
+	uc.record(ctx, audit.ActionDisconnect, dto.UserId, dto.ConnectionTo, err)
 	if err != nil {
 		return err
 	}
 
+	uc.invalidateMutual(ctx, dto.UserId)
+	uc.invalidateSuggestions(ctx, dto.UserId, dto.ConnectionTo)
 	return nil
 }
 
-func (uc *UseCase) Disconnect(ctx context.Context, dto *CreateConnectionDTO) error {
-	err := uc.connectionRepository.Delete(ctx, dto.UserId, dto.ConnectionTo)
+// ListConnections returns a page of the people id is connected to and the
+// cursor of the next page, empty on the last one.
+func (uc *UseCase) ListConnections(ctx context.Context, id uuid.UUID, q *ListQuery) ([]ConnectionsItem, string, error) {
+	return uc.list(ctx, uc.connectionRepository.Find, id, q)
+}
+
+// ListFollowers returns a page of the people connected to id, like
+// ListConnections.
+func (uc *UseCase) ListFollowers(ctx context.Context, id uuid.UUID, q *ListQuery) ([]ConnectionsItem, string, error) {
+	return uc.list(ctx, uc.connectionRepository.FindInbound, id, q)
+}
+
+// Counts returns the numbers of followers and followed people of id.
+func (uc *UseCase) Counts(ctx context.Context, id uuid.UUID) (*Counts, error) {
+	return uc.connectionRepository.Count(ctx, id)
+}
+
+func (uc *UseCase) list(ctx context.Context, find func(context.Context, uuid.UUID, *ListQuery) ([]ConnectionsItem, error), id uuid.UUID, q *ListQuery) ([]ConnectionsItem, string, error) {
+	if q.Sort == "" {
+		q.Sort = SortRecent
+	}
+	if q.Sort != SortRecent && q.Sort != SortName {
+		return nil, "", ErrInvalidSort
+	}
+	// A cursor only continues the order it was issued for.
+	if q.Cursor != nil && q.Cursor.Sort != q.Sort {
+		return nil, "", ErrInvalidCursor
+	}
+	if prefix := []rune(q.Prefix); len(prefix) > maxPrefixLength {
+		q.Prefix = string(prefix[:maxPrefixLength])
+	}
+	q.Limit = clampLimit(q.Limit)
+
+	connections, err := find(ctx, id, q)
+	if err != nil {
+		return nil, "", err
+	}
+	if connections == nil {
+		connections = []ConnectionsItem{}
+	}
+
+	if len(connections) < q.Limit {
+		return connections, "", nil
+	}
+
+	cursor, err := cursorAfter(&connections[len(connections)-1], q.Sort)
+	if err != nil {
+		return nil, "", err
+	}
+	next, err := EncodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return connections, next, nil
+}
+
+// ListIncoming returns the requests waiting for id to accept or reject them.
+func (uc *UseCase) ListIncoming(ctx context.Context, id uuid.UUID) ([]ConnectionsItem, error) {
+	return uc.connectionRepository.FindIncoming(ctx, id)
+}
+
+// ListOutgoing returns the requests id made that were not accepted.
+func (uc *UseCase) ListOutgoing(ctx context.Context, id uuid.UUID) ([]ConnectionsItem, error) {
+	return uc.connectionRepository.FindOutgoing(ctx, id)
+}
+
+// Accept accepts the request of requester to connect to personId. Rejected
+// requests can still be accepted later.
+func (uc *UseCase) Accept(ctx context.Context, personId, requester uuid.UUID) error {
+	err := uc.connectionRepository.Accept(ctx, requester, personId)
+	uc.record(ctx, audit.ActionConnectAccept, personId, requester, err)
+	if err != nil {
+		return err
+	}
+
+	uc.invalidateMutual(ctx, requester)
+	uc.invalidateSuggestions(ctx, requester)
+	return nil
+}
+
+// Reject rejects the pending request of requester to connect to personId.
+// The requester cannot ask again until personId accepts it after all.
+func (uc *UseCase) Reject(ctx context.Context, personId, requester uuid.UUID) error {
+	err := uc.connectionRepository.Reject(ctx, requester, personId)
+	uc.record(ctx, audit.ActionConnectReject, personId, requester, err)
+	return err
+}
+
+// Cancel withdraws the pending request of personId to connect to recipient.
+func (uc *UseCase) Cancel(ctx context.Context, personId, recipient uuid.UUID) error {
+	err := uc.connectionRepository.DeletePending(ctx, personId, recipient)
+	uc.record(ctx, audit.ActionConnectCancel, personId, recipient, err)
+	return err
+}
+
+// Block blocks blockedId for blockerId and removes the connections between
+// them in both directions.
+func (uc *UseCase) Block(ctx context.Context, blockerId, blockedId uuid.UUID) error {
+	err := uc.blockRepository.Save(ctx, &Block{
+		BlockerId: blockerId,
+		BlockedId: blockedId,
+	})
+	if err == nil {
+		_, err = uc.connectionRepository.Delete(ctx, blockerId, blockedId)
+	}
+	if err == nil {
+		_, err = uc.connectionRepository.Delete(ctx, blockedId, blockerId)
+	}
+	uc.record(ctx, audit.ActionBlock, blockerId, blockedId, err)
 	if err != nil {
 		return err
 	}
 
+	uc.invalidateMutual(ctx, blockerId, blockedId)
+	uc.invalidateSuggestions(ctx, blockerId, blockedId)
 	return nil
 }
 
-func (uc *UseCase) ListConnections(ctx context.Context, id uuid.UUID) ([]ConnectionsItem, error) {
-	connections, err := uc.connectionRepository.Find(ctx, id)
+func (uc *UseCase) Unblock(ctx context.Context, blockerId, blockedId uuid.UUID) error {
+	err := uc.blockRepository.Delete(ctx, blockerId, blockedId)
+	uc.record(ctx, audit.ActionUnblock, blockerId, blockedId, err)
+	if err != nil {
+		return err
+	}
+
+	// Blocks hide people from the mutual connections and suggestions of
+	// both.
+	uc.invalidateMutual(ctx, blockerId, blockedId)
+	uc.invalidateSuggestions(ctx, blockerId, blockedId)
+	return nil
+}
+
+func (uc *UseCase) ListBlocked(ctx context.Context, blockerId uuid.UUID) ([]ConnectionsItem, error) {
+	return uc.blockRepository.Find(ctx, blockerId)
+}
+
+// Blocked reports whether either person blocked the other.
+func (uc *UseCase) Blocked(ctx context.Context, id, otherId uuid.UUID) (bool, error) {
+	return uc.blockRepository.Blocked(ctx, id, otherId)
+}
+
+// MutualConnections returns a page of the people both id and otherId are
+// connected to, with their number. Pages are served from the cache when
+// possible; a failing cache is logged and bypassed.
+func (uc *UseCase) MutualConnections(ctx context.Context, id, otherId, after uuid.UUID, limit int) (*MutualConnections, error) {
+	limit = clampLimit(limit)
+
+	blocked, err := uc.blockRepository.Blocked(ctx, id, otherId)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrBlocked
+	}
+
+	page, err := uc.mutualCache.Find(ctx, id, otherId, after, limit)
+	if err != nil {
+		uc.logger.Errorw("error while reading cached mutual connections", "error", err)
+	}
+	if page != nil {
+		return page, nil
+	}
+
+	items, err := uc.connectionRepository.FindMutual(ctx, id, otherId, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := uc.connectionRepository.CountMutual(ctx, id, otherId)
+	if err != nil {
+		return nil, err
+	}
+
+	page = &MutualConnections{
+		Count:       count,
+		Connections: items,
+	}
+	if page.Connections == nil {
+		page.Connections = []ConnectionsItem{}
+	}
+	if len(items) == limit {
+		next, err := uuid.Parse(items[len(items)-1].Id)
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = &next
+	}
+
+	err = uc.mutualCache.Save(ctx, id, otherId, after, limit, page)
+	if err != nil {
+		uc.logger.Errorw("error while caching mutual connections", "error", err)
+	}
+
+	return page, nil
+}
+
+// invalidateMutual drops the cached mutual connections of ids after their
+// connections or blocks changed.
+func (uc *UseCase) invalidateMutual(ctx context.Context, ids ...uuid.UUID) {
+	err := uc.mutualCache.Invalidate(ctx, ids...)
+	if err != nil {
+		uc.logger.Errorw("error while invalidating mutual connections", "error", err)
+	}
+}
+
+func (uc *UseCase) Settings(ctx context.Context, personId uuid.UUID) (*Settings, error) {
+	return uc.settingsRepository.Find(ctx, personId)
+}
+
+func (uc *UseCase) UpdateSettings(ctx context.Context, dto *UpdateSettingsDTO) (*Settings, error) {
+	settings := &Settings{
+		PersonId:   dto.PersonId,
+		AutoAccept: *dto.AutoAccept,
+	}
+
+	err := uc.settingsRepository.Save(ctx, settings)
 	if err != nil {
 		return nil, err
 	}
 
-	return connections, nil
+	return settings, nil
 }
 
-func NewUseCase(connectionRepository Repository) *UseCase {
-	return &UseCase{connectionRepository: connectionRepository}
+func clampLimit(limit int) int {
+	if limit <= 0 {
+		return defaultLimit
+	}
+	if limit > maxLimit {
+		return maxLimit
+	}
+	return limit
+}
+
+func (uc *UseCase) record(ctx context.Context, action string, actorId, subjectId uuid.UUID, err error) {
+	uc.audit.Record(ctx, &audit.Event{
+		ActorId:   actorId,
+		SubjectId: subjectId,
+		Action:    action,
+		Outcome:   audit.Outcome(err),
+	})
+}
+
+func NewUseCase(connectionRepository Repository, settingsRepository SettingsRepository, blockRepository BlockRepository, mutualCache MutualConnectionsCache, suggestionRepository SuggestionRepository, suggestionCache SuggestionCache, auditUC *audit.UseCase, conf *pkg.Config, logger *zap.SugaredLogger) *UseCase {
+	return &UseCase{
+		connectionRepository: connectionRepository,
+		settingsRepository:   settingsRepository,
+		blockRepository:      blockRepository,
+		mutualCache:          mutualCache,
+		suggestionRepository: suggestionRepository,
+		suggestionCache:      suggestionCache,
+		audit:                auditUC,
+		conf:                 conf,
+		logger:               logger,
+	}
 }
@@ -0,0 +1,20 @@
+package invite
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type CreateInviteRequest struct {
+	CreatedBy      uuid.UUID `json:"createdBy"`
+	MaxUses        int       `json:"maxUses" validate:"required,min=1,max=100"`
+	ExpiresInHours int       `json:"expiresInHours" validate:"required,min=1,max=720"`
+}
+
+type InviteItem struct {
+	Code            string    `json:"code"`
+	MaxUses         int       `json:"maxUses"`
+	Uses            int       `json:"uses"`
+	ExpiresAt       time.Time `json:"expiresAt"`
+	DatetimeCreated time.Time `json:"datetimeCreated"`
+}
@@ -0,0 +1,22 @@
+package invite
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type Invite struct {
+	Code            string
+	CreatedBy       uuid.UUID
+	MaxUses         int
+	Uses            int
+	ExpiresAt       time.Time
+	DatetimeCreated time.Time
+}
+
+type Redemption struct {
+	Code            string
+	InvitedBy       uuid.UUID
+	Invitee         uuid.UUID
+	DatetimeCreated time.Time
+}
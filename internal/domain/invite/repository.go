@@ -0,0 +1,19 @@
+package invite
+
+import (
+	"context"
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	// Save stores invite unless its creator already has as many active
+	// invites as their quota allows, in which case ErrInviteQuotaReached is
+	// returned. Active invites are unexpired and have uses left.
+	Save(ctx context.Context, invite *Invite) error
+	Find(ctx context.Context, code string) (*Invite, error)
+	FindByCreator(ctx context.Context, createdBy uuid.UUID) ([]Invite, error)
+	// Claim atomically consumes one use of a valid invite and returns it.
+	// sql.ErrNoRows is returned when the code is unknown, expired or exhausted.
+	Claim(ctx context.Context, code string) (*Invite, error)
+	Release(ctx context.Context, code string) error
+}
@@ -0,0 +1,182 @@
+package invite
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/connections"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+type UseCase struct {
+	inviteRepository Repository
+	connectionsUC    *connections.UseCase
+	logger           *zap.SugaredLogger
+	conf             *pkg.Config
+}
+
+var (
+	ErrInviteRequired     = errors.New("an invite code is required to register")
+	ErrInviteInvalid      = errors.New("invite code is invalid, expired or used up")
+	ErrInviteQuotaReached = errors.New("invite quota reached")
+)
+
+const codeLength = 10
+
+// Required reports whether registration is limited to invited users.
+func (uc *UseCase) Required() bool {
+	return uc.conf.InviteOnly
+}
+
+func (uc *UseCase) Create(ctx context.Context, dto *CreateInviteRequest) (*InviteItem, error) {
+	code, err := generateCode()
+	if err != nil {
+		return nil, err
+	}
+
+	invite := &Invite{
+		Code:      code,
+		CreatedBy: dto.CreatedBy,
+		MaxUses:   dto.MaxUses,
+		ExpiresAt: time.Now().Add(time.Duration(dto.ExpiresInHours) * time.Hour),
+	}
+	err = uc.inviteRepository.Save(ctx, invite)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InviteItem{
+		Code:            invite.Code,
+		MaxUses:         invite.MaxUses,
+		ExpiresAt:       invite.ExpiresAt,
+		DatetimeCreated: time.Now(),
+	}, nil
+}
+
+func (uc *UseCase) List(ctx context.Context, createdBy uuid.UUID) ([]InviteItem, error) {
+	invites, err := uc.inviteRepository.FindByCreator(ctx, createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]InviteItem, 0, len(invites))
+	for _, invite := range invites {
+		items = append(items, InviteItem{
+			Code:            invite.Code,
+			MaxUses:         invite.MaxUses,
+			Uses:            invite.Uses,
+			ExpiresAt:       invite.ExpiresAt,
+			DatetimeCreated: invite.DatetimeCreated,
+		})
+	}
+	return items, nil
+}
+
+// Check validates a code without consuming it, so registration can fail fast
+// before any work is done.
+func (uc *UseCase) Check(ctx context.Context, code string) error {
+	if code == "" {
+		return ErrInviteRequired
+	}
+
+	invite, err := uc.inviteRepository.Find(ctx, normalizeCode(code))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInviteInvalid
+		}
+		return err
+	}
+
+	if invite.Uses >= invite.MaxUses || time.Now().After(invite.ExpiresAt) {
+		return ErrInviteInvalid
+	}
+	return nil
+}
+
+// Claim consumes one use of code. The returned invite must either be
+// recorded as redeemed along with the invitee and passed to Redeem, or given
+// back with Release.
+func (uc *UseCase) Claim(ctx context.Context, code string) (*Invite, error) {
+	if code == "" {
+		return nil, ErrInviteRequired
+	}
+
+	invite, err := uc.inviteRepository.Claim(ctx, normalizeCode(code))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInviteInvalid
+		}
+		return nil, err
+	}
+	return invite, nil
+}
+
+func (uc *UseCase) Release(ctx context.Context, invite *Invite) error {
+	return uc.inviteRepository.Release(ctx, invite.Code)
+}
+
+// Redemption returns the record of invitee joining through invite, which is
+// stored along with the invitee.
+func (uc *UseCase) Redemption(invite *Invite, invitee uuid.UUID) *Redemption {
+	return &Redemption{
+		Code:      invite.Code,
+		InvitedBy: invite.CreatedBy,
+		Invitee:   invitee,
+	}
+}
+
+// Redeem finishes the registration of invitee through invite: when enabled,
+// it connects the invitee and the member who invited them. Failures are
+// logged, the registration stands.
+func (uc *UseCase) Redeem(ctx context.Context, invite *Invite, invitee uuid.UUID) {
+	if !uc.conf.InviteAutoConnect {
+		return
+	}
+
+	err := uc.connectionsUC.ConnectAccepted(ctx, &connections.CreateConnectionDTO{
+		UserId:       invitee,
+		ConnectionTo: invite.CreatedBy,
+	})
+	if err != nil {
+		uc.logger.Errorw("error while connecting invitee to inviter", "error", err)
+		return
+	}
+
+	err = uc.connectionsUC.ConnectAccepted(ctx, &connections.CreateConnectionDTO{
+		UserId:       invite.CreatedBy,
+		ConnectionTo: invitee,
+	})
+	if err != nil {
+		uc.logger.Errorw("error while connecting inviter to invitee", "error", err)
+	}
+}
+
+func generateCode() (string, error) {
+	b := make([]byte, codeLength)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	code := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	return code[:codeLength], nil
+}
+
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func NewUseCase(inviteRepository Repository, connectionsUC *connections.UseCase, logger *zap.SugaredLogger, conf *pkg.Config) *UseCase {
+	return &UseCase{
+		inviteRepository: inviteRepository,
+		connectionsUC:    connectionsUC,
+		logger:           logger,
+		conf:             conf,
+	}
+}
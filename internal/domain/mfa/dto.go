@@ -0,0 +1,14 @@
+package mfa
+
+type EnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauthUri"`
+}
+
+type CodeRequest struct {
+	Code string `json:"code" validate:"required,min=6,max=16"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
@@ -0,0 +1,21 @@
+package mfa
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type TOTPFactor struct {
+	PersonId        uuid.UUID
+	Secret          string
+	Confirmed       bool
+	LastUsedStep    int64
+	DatetimeCreated time.Time
+}
+
+type RecoveryCode struct {
+	PersonId        uuid.UUID
+	CodeHash        string
+	UsedAt          *time.Time
+	DatetimeCreated time.Time
+}
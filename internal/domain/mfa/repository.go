@@ -0,0 +1,20 @@
+package mfa
+
+import (
+	"context"
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	SaveFactor(ctx context.Context, factor *TOTPFactor) error
+	FindFactor(ctx context.Context, personId uuid.UUID) (*TOTPFactor, error)
+	ConfirmFactor(ctx context.Context, personId uuid.UUID) error
+	DeleteFactor(ctx context.Context, personId uuid.UUID) error
+	// UseStep records step as the last accepted code. It returns false when a
+	// code for the same or a later step was already accepted.
+	UseStep(ctx context.Context, personId uuid.UUID, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, personId uuid.UUID, codeHashes []string) error
+	// UseRecoveryCode marks an unused code as used, returning false when it
+	// does not exist or was already used.
+	UseRecoveryCode(ctx context.Context, personId uuid.UUID, codeHash string) (bool, error)
+}
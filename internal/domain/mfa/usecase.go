@@ -0,0 +1,215 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
+	"strings"
+	"time"
+)
+
+type UseCase struct {
+	repository Repository
+	conf       *pkg.Config
+}
+
+var (
+	ErrAlreadyEnrolled = errors.New("multi-factor authentication is already enabled")
+	ErrNotEnrolled     = errors.New("multi-factor authentication is not enabled")
+	ErrInvalidCode     = errors.New("invalid verification code")
+)
+
+const (
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+	totpSkew           = 1
+)
+
+// Enabled reports whether personId has a confirmed TOTP factor.
+func (uc *UseCase) Enabled(ctx context.Context, personId uuid.UUID) (bool, error) {
+	factor, err := uc.repository.FindFactor(ctx, personId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return factor.Confirmed, nil
+}
+
+// Enroll starts TOTP enrollment. The factor stays inactive until Confirm is
+// called with a code from the authenticator app; enrolling again before that
+// replaces the pending secret.
+func (uc *UseCase) Enroll(ctx context.Context, personId uuid.UUID, account string) (*EnrollmentResponse, error) {
+	enabled, err := uc.Enabled(ctx, personId)
+	if err != nil {
+		return nil, err
+	}
+
+	if enabled {
+		return nil, ErrAlreadyEnrolled
+	}
+
+	secret, err := pkg.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.repository.SaveFactor(ctx, &TOTPFactor{
+		PersonId: personId,
+		Secret:   secret,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &EnrollmentResponse{
+		Secret:     secret,
+		OtpauthURI: pkg.TOTPURI(uc.conf.MFAIssuer, account, secret),
+	}, nil
+}
+
+// Confirm activates a pending factor and issues a fresh set of recovery
+// codes. The plaintext codes are only ever returned here.
+func (uc *UseCase) Confirm(ctx context.Context, personId uuid.UUID, code string) (*RecoveryCodesResponse, error) {
+	factor, err := uc.repository.FindFactor(ctx, personId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotEnrolled
+		}
+		return nil, err
+	}
+
+	if factor.Confirmed {
+		return nil, ErrAlreadyEnrolled
+	}
+
+	ok, err := uc.verifyTOTP(ctx, factor, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	err = uc.repository.ConfirmFactor(ctx, personId)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.regenerateRecoveryCodes(ctx, personId)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking code.
+func (uc *UseCase) RegenerateRecoveryCodes(ctx context.Context, personId uuid.UUID, code string) (*RecoveryCodesResponse, error) {
+	err := uc.Verify(ctx, personId, code)
+	if err != nil {
+		return nil, err
+	}
+	return uc.regenerateRecoveryCodes(ctx, personId)
+}
+
+func (uc *UseCase) Disable(ctx context.Context, personId uuid.UUID, code string) error {
+	err := uc.Verify(ctx, personId, code)
+	if err != nil {
+		return err
+	}
+	return uc.repository.DeleteFactor(ctx, personId)
+}
+
+// Verify accepts either a current TOTP code or an unused recovery code.
+func (uc *UseCase) Verify(ctx context.Context, personId uuid.UUID, code string) error {
+	factor, err := uc.repository.FindFactor(ctx, personId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotEnrolled
+		}
+		return err
+	}
+
+	if !factor.Confirmed {
+		return ErrNotEnrolled
+	}
+
+	ok, err := uc.verifyTOTP(ctx, factor, code)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		return nil
+	}
+
+	ok, err = uc.repository.UseRecoveryCode(ctx, personId, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+func (uc *UseCase) verifyTOTP(ctx context.Context, factor *TOTPFactor, code string) (bool, error) {
+	step, ok, err := pkg.ValidateTOTP(factor.Secret, strings.TrimSpace(code), time.Now(), totpSkew)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	// Each code is only accepted once, even within its validity window.
+	return uc.repository.UseStep(ctx, factor.PersonId, step)
+}
+
+func (uc *UseCase) regenerateRecoveryCodes(ctx context.Context, personId uuid.UUID) (*RecoveryCodesResponse, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	err := uc.repository.ReplaceRecoveryCodes(ctx, personId, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeLength)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+	return code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:recoveryCodeLength], nil
+}
+
+// hashRecoveryCode normalises user input so codes can be typed without the
+// dash or in any case. Recovery codes are random, so a fast hash suffices.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func NewUseCase(repository Repository, conf *pkg.Config) *UseCase {
+	return &UseCase{
+		repository: repository,
+		conf:       conf,
+	}
+}
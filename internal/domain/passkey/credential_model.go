@@ -0,0 +1,21 @@
+package passkey
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type Credential struct {
+	Id              []byte
+	PersonId        uuid.UUID
+	Name            string
+	PublicKey       []byte
+	AttestationType string
+	AAGUID          []byte
+	SignCount       uint32
+	Transports      []string
+	BackupEligible  bool
+	BackupState     bool
+	DatetimeCreated time.Time
+	LastUsed        *time.Time
+}
@@ -0,0 +1,40 @@
+package passkey
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Owner describes the person a credential is being registered for.
+type Owner struct {
+	Id          uuid.UUID
+	Username    string
+	DisplayName string
+}
+
+// CeremonyResponse carries the options passed to navigator.credentials in
+// the browser. CeremonyId must be sent back when finishing the ceremony.
+type CeremonyResponse struct {
+	CeremonyId string `json:"ceremonyId"`
+	Options    any    `json:"options"`
+}
+
+type FinishRegistrationRequest struct {
+	CeremonyId string          `json:"ceremonyId" validate:"required"`
+	Name       string          `json:"name" validate:"max=64"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+type AssertionRequest struct {
+	CeremonyId string          `json:"ceremonyId" validate:"required"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+type CredentialItem struct {
+	Id              string     `json:"id"`
+	Name            string     `json:"name"`
+	BackupEligible  bool       `json:"backupEligible"`
+	DatetimeCreated time.Time  `json:"datetimeCreated"`
+	LastUsed        *time.Time `json:"lastUsed"`
+}
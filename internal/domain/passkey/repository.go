@@ -0,0 +1,24 @@
+package passkey
+
+import (
+	"context"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	Save(ctx context.Context, credential *Credential) error
+	Find(ctx context.Context, id []byte) (*Credential, error)
+	FindByPerson(ctx context.Context, personId uuid.UUID) ([]Credential, error)
+	UpdateSignCount(ctx context.Context, id []byte, signCount uint32, backupState bool) error
+	Delete(ctx context.Context, personId uuid.UUID, id []byte) error
+}
+
+// SessionRepository keeps the server side state of a ceremony between the
+// options and the verification request.
+type SessionRepository interface {
+	Save(ctx context.Context, key string, session *webauthn.SessionData) error
+	// Take returns and removes the session so it cannot be replayed. It
+	// returns ErrCeremonyNotFound for unknown or expired keys.
+	Take(ctx context.Context, key string) (*webauthn.SessionData, error)
+}
@@ -0,0 +1,292 @@
+package passkey
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+type UseCase struct {
+	repository        Repository
+	sessionRepository SessionRepository
+	webAuthn          *webauthn.WebAuthn
+}
+
+var (
+	ErrCeremonyNotFound   = errors.New("webauthn ceremony not found or expired")
+	ErrNoCredentials      = errors.New("no passkeys registered")
+	ErrCredentialNotFound = errors.New("passkey not found")
+	ErrVerificationFailed = errors.New("passkey verification failed")
+	ErrCloneDetected      = errors.New("passkey sign count went backwards, the authenticator may be cloned")
+)
+
+const (
+	registrationKeyPrefix = "registration"
+	loginKeyPrefix        = "login"
+	passwordlessKeyPrefix = "passwordless"
+)
+
+func (uc *UseCase) HasCredentials(ctx context.Context, personId uuid.UUID) (bool, error) {
+	creds, err := uc.repository.FindByPerson(ctx, personId)
+	if err != nil {
+		return false, err
+	}
+	return len(creds) > 0, nil
+}
+
+func (uc *UseCase) List(ctx context.Context, personId uuid.UUID) ([]CredentialItem, error) {
+	creds, err := uc.repository.FindByPerson(ctx, personId)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]CredentialItem, 0, len(creds))
+	for _, c := range creds {
+		items = append(items, CredentialItem{
+			Id:              base64.RawURLEncoding.EncodeToString(c.Id),
+			Name:            c.Name,
+			BackupEligible:  c.BackupEligible,
+			DatetimeCreated: c.DatetimeCreated,
+			LastUsed:        c.LastUsed,
+		})
+	}
+	return items, nil
+}
+
+func (uc *UseCase) Delete(ctx context.Context, personId uuid.UUID, id string) error {
+	rawId, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return ErrCredentialNotFound
+	}
+	return uc.repository.Delete(ctx, personId, rawId)
+}
+
+// BeginRegistration returns creation options for a new passkey. Discoverable
+// credentials are preferred so the passkey can also be used without a
+// username.
+func (uc *UseCase) BeginRegistration(ctx context.Context, owner *Owner) (*CeremonyResponse, error) {
+	user, err := uc.loadUser(ctx, owner.Id)
+	if err != nil {
+		return nil, err
+	}
+	user.name = owner.Username
+	user.displayName = owner.DisplayName
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, c := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+
+	creation, session, err := uc.webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.saveSession(ctx, registrationKeyPrefix, session, creation)
+}
+
+func (uc *UseCase) FinishRegistration(ctx context.Context, personId uuid.UUID, dto *FinishRegistrationRequest) (*CredentialItem, error) {
+	session, err := uc.takeSession(ctx, registrationKeyPrefix, dto.CeremonyId)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(dto.Credential))
+	if err != nil {
+		return nil, errors.Join(ErrVerificationFailed, err)
+	}
+
+	user, err := uc.loadUser(ctx, personId)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := uc.webAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return nil, errors.Join(ErrVerificationFailed, err)
+	}
+
+	transports := make([]string, 0, len(cred.Transport))
+	for _, t := range cred.Transport {
+		transports = append(transports, string(t))
+	}
+
+	credential := &Credential{
+		Id:              cred.ID,
+		PersonId:        personId,
+		Name:            dto.Name,
+		PublicKey:       cred.PublicKey,
+		AttestationType: cred.AttestationType,
+		AAGUID:          cred.Authenticator.AAGUID,
+		SignCount:       cred.Authenticator.SignCount,
+		Transports:      transports,
+		BackupEligible:  cred.Flags.BackupEligible,
+		BackupState:     cred.Flags.BackupState,
+	}
+	err = uc.repository.Save(ctx, credential)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CredentialItem{
+		Id:             base64.RawURLEncoding.EncodeToString(credential.Id),
+		Name:           credential.Name,
+		BackupEligible: credential.BackupEligible,
+	}, nil
+}
+
+// BeginLogin returns assertion options restricted to the passkeys of a
+// person that has already been identified, i.e. passkey as a second factor.
+func (uc *UseCase) BeginLogin(ctx context.Context, personId uuid.UUID) (*CeremonyResponse, error) {
+	user, err := uc.loadUser(ctx, personId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(user.credentials) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	assertion, session, err := uc.webAuthn.BeginLogin(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.saveSession(ctx, loginKeyPrefix, session, assertion)
+}
+
+func (uc *UseCase) FinishLogin(ctx context.Context, personId uuid.UUID, dto *AssertionRequest) error {
+	session, err := uc.takeSession(ctx, loginKeyPrefix, dto.CeremonyId)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(dto.Credential))
+	if err != nil {
+		return errors.Join(ErrVerificationFailed, err)
+	}
+
+	user, err := uc.loadUser(ctx, personId)
+	if err != nil {
+		return err
+	}
+
+	cred, err := uc.webAuthn.ValidateLogin(user, *session, parsed)
+	if err != nil {
+		return errors.Join(ErrVerificationFailed, err)
+	}
+
+	return uc.recordUse(ctx, cred)
+}
+
+// BeginPasswordless returns assertion options without an allow list so the
+// authenticator can offer any discoverable passkey for this site.
+func (uc *UseCase) BeginPasswordless(ctx context.Context) (*CeremonyResponse, error) {
+	assertion, session, err := uc.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.saveSession(ctx, passwordlessKeyPrefix, session, assertion)
+}
+
+// FinishPasswordless verifies a discoverable assertion and returns the id of
+// the person that owns the passkey.
+func (uc *UseCase) FinishPasswordless(ctx context.Context, dto *AssertionRequest) (uuid.UUID, error) {
+	session, err := uc.takeSession(ctx, passwordlessKeyPrefix, dto.CeremonyId)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(dto.Credential))
+	if err != nil {
+		return uuid.Nil, errors.Join(ErrVerificationFailed, err)
+	}
+
+	var owner uuid.UUID
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		personId, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+
+		user, err := uc.loadUser(ctx, personId)
+		if err != nil {
+			return nil, err
+		}
+
+		owner = personId
+		return user, nil
+	}
+
+	cred, err := uc.webAuthn.ValidateDiscoverableLogin(handler, *session, parsed)
+	if err != nil {
+		return uuid.Nil, errors.Join(ErrVerificationFailed, err)
+	}
+
+	err = uc.recordUse(ctx, cred)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return owner, nil
+}
+
+// recordUse stores the new signature counter of an authenticator, refusing
+// the login when the counter shows the credential has been cloned.
+func (uc *UseCase) recordUse(ctx context.Context, cred *webauthn.Credential) error {
+	if cred.Authenticator.CloneWarning {
+		return ErrCloneDetected
+	}
+	return uc.repository.UpdateSignCount(ctx, cred.ID, cred.Authenticator.SignCount, cred.Flags.BackupState)
+}
+
+func (uc *UseCase) loadUser(ctx context.Context, personId uuid.UUID) (*webauthnUser, error) {
+	creds, err := uc.repository.FindByPerson(ctx, personId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webauthnUser{
+		id:          personId,
+		credentials: creds,
+	}, nil
+}
+
+func (uc *UseCase) saveSession(ctx context.Context, prefix string, session *webauthn.SessionData, options any) (*CeremonyResponse, error) {
+	ceremonyId, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.sessionRepository.Save(ctx, prefix+"-"+ceremonyId.String(), session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CeremonyResponse{
+		CeremonyId: ceremonyId.String(),
+		Options:    options,
+	}, nil
+}
+
+func (uc *UseCase) takeSession(ctx context.Context, prefix, ceremonyId string) (*webauthn.SessionData, error) {
+	return uc.sessionRepository.Take(ctx, prefix+"-"+ceremonyId)
+}
+
+func NewUseCase(repository Repository, sessionRepository SessionRepository, webAuthn *webauthn.WebAuthn) *UseCase {
+	return &UseCase{
+		repository:        repository,
+		sessionRepository: sessionRepository,
+		webAuthn:          webAuthn,
+	}
+}
@@ -0,0 +1,58 @@
+package passkey
+
+import (
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+// webauthnUser adapts a person and their stored credentials to the
+// webauthn.User interface. The user handle is the raw person id.
+type webauthnUser struct {
+	id          uuid.UUID
+	name        string
+	displayName string
+	credentials []Credential
+}
+
+func (u *webauthnUser) WebAuthnID() []byte {
+	return u.id[:]
+}
+
+func (u *webauthnUser) WebAuthnName() string {
+	return u.name
+}
+
+func (u *webauthnUser) WebAuthnDisplayName() string {
+	return u.displayName
+}
+
+func (u *webauthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	creds := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, c := range u.credentials {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(c.Transports))
+		for _, t := range c.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+
+		creds = append(creds, webauthn.Credential{
+			ID:              c.Id,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: c.BackupEligible,
+				BackupState:    c.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.AAGUID,
+				SignCount: c.SignCount,
+			},
+		})
+	}
+	return creds
+}
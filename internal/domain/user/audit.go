@@ -0,0 +1,81 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/audit"
+	"github.com/oyamo/forumz-auth-server/internal/domain/mfa"
+	"github.com/oyamo/forumz-auth-server/internal/domain/passkey"
+)
+
+// Login methods recorded in the details of login audit events.
+const (
+	loginMethodPassword  = "password"
+	loginMethodTOTP      = "totp"
+	loginMethodWebAuthn  = "webauthn"
+	loginMethodPasskey   = "passkey"
+	loginMethodMagicLink = "magic_link"
+)
+
+// auditLogin records a login attempt against subject, which is uuid.Nil when
+// the account is unknown; username is recorded for those.
+func (u *UseCase) auditLogin(ctx context.Context, subject uuid.UUID, method, username string, challenge *MFAChallenge, err error) {
+	event := &audit.Event{
+		SubjectId: subject,
+		Action:    audit.ActionLogin,
+		Outcome:   audit.Outcome(err),
+		Details:   map[string]any{"method": method},
+	}
+
+	switch {
+	case err != nil:
+		event.Details["reason"] = loginFailureReason(err)
+		if subject == uuid.Nil && username != "" {
+			event.Details["username"] = username
+		}
+	case challenge != nil:
+		event.ActorId = subject
+		event.Outcome = audit.OutcomeMFARequired
+	default:
+		event.ActorId = subject
+	}
+
+	u.audit.Record(ctx, event)
+}
+
+// auditAction records an action a person performed on their own account.
+func (u *UseCase) auditAction(ctx context.Context, action string, personId uuid.UUID, err error) {
+	u.audit.Record(ctx, &audit.Event{
+		ActorId:   personId,
+		SubjectId: personId,
+		Action:    action,
+		Outcome:   audit.Outcome(err),
+	})
+}
+
+func loginFailureReason(err error) string {
+	var lockoutErr *LockoutError
+	switch {
+	case errors.As(err, &lockoutErr):
+		return "locked"
+	case errors.Is(err, ErrChallengeRequired):
+		return "challenge_required"
+	case errors.Is(err, ErrUserNotFound):
+		return "unknown_user"
+	case errors.Is(err, ErrIncorrectCredentials):
+		return "incorrect_credentials"
+	case errors.Is(err, ErrInvalidChallenge):
+		return "invalid_challenge"
+	case errors.Is(err, ErrInvalidMagicLink):
+		return "invalid_magic_link"
+	case errors.Is(err, mfa.ErrInvalidCode):
+		return "invalid_code"
+	case errors.Is(err, passkey.ErrVerificationFailed), errors.Is(err, passkey.ErrCeremonyNotFound):
+		return "passkey_verification_failed"
+	case errors.Is(err, passkey.ErrCloneDetected):
+		return "passkey_clone_detected"
+	default:
+		return "error"
+	}
+}
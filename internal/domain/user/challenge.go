@@ -0,0 +1,107 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/challenge"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrChallengeRequired = errors.New("challenge required")
+
+// ChallengeError asks the client to solve Challenge and send the answer with
+// its retry. Rejected is set when an answer was sent but did not pass. It
+// matches ErrChallengeRequired with errors.Is.
+type ChallengeError struct {
+	Challenge *challenge.Challenge
+	Rejected  error
+}
+
+func (e *ChallengeError) Error() string {
+	if e.Rejected != nil {
+		return fmt.Sprintf("%s: %s", ErrChallengeRequired, e.Rejected)
+	}
+	return ErrChallengeRequired.Error()
+}
+
+func (e *ChallengeError) Unwrap() error {
+	return ErrChallengeRequired
+}
+
+// requireChallenge passes when risky is false or response answers a
+// challenge; otherwise it returns a *ChallengeError with a fresh challenge.
+func (u *UseCase) requireChallenge(ctx context.Context, risky bool, response, ip string) error {
+	span := trace.SpanFromContext(ctx)
+
+	if !risky || u.challenges == nil {
+		return nil
+	}
+
+	var rejected error
+	if response != "" {
+		span.AddEvent("u.challenges.Verify")
+		err := u.challenges.Verify(ctx, response, ip)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, challenge.ErrChallengeFailed) {
+			u.logger.Errorw("error while verifying challenge", "error", err)
+			span.RecordError(err)
+			return err
+		}
+		rejected = err
+	}
+
+	span.AddEvent("u.challenges.Issue")
+	issued, err := u.challenges.Issue(ctx)
+	if err != nil {
+		u.logger.Errorw("error while issuing challenge", "error", err)
+		span.RecordError(err)
+		return err
+	}
+
+	return &ChallengeError{Challenge: issued, Rejected: rejected}
+}
+
+// loginRisky reports whether a login must solve a challenge: keys have
+// reached conf.ChallengeAfterFailures failures, or, when enabled, personId
+// logs in from an IP block it never used before. Unknown usernames, with a
+// nil personId, never used any, so that the challenge does not tell them
+// apart from accounts.
+func (u *UseCase) loginRisky(ctx context.Context, personId uuid.UUID, ip string, keys ...attemptKey) bool {
+	threshold := u.conf.ChallengeAfterFailures
+	if threshold > 0 && u.guard.Failures(ctx, keys...) >= int64(threshold) {
+		return true
+	}
+
+	if !u.conf.ChallengeNewNetworks {
+		return false
+	}
+	if personId == uuid.Nil {
+		return true
+	}
+
+	newNetwork, err := u.monitor.NewNetwork(ctx, personId, ip)
+	if err != nil {
+		u.logger.Errorw("error while checking known networks", "error", err)
+		return false
+	}
+	return newNetwork
+}
+
+// registrationRisky counts a registration against the IP block of ip and
+// reports whether the block went past conf.ChallengeAfterRegistrations
+// within conf.ChallengeWindow, or the IP reached the login failure threshold.
+func (u *UseCase) registrationRisky(ctx context.Context, ip string) bool {
+	if threshold := u.conf.ChallengeAfterRegistrations; threshold > 0 {
+		count := u.guard.Count(ctx, registrationKey(ip), u.conf.ChallengeWindow)
+		if count > int64(threshold) {
+			return true
+		}
+	}
+
+	threshold := u.conf.ChallengeAfterFailures
+	return threshold > 0 && u.guard.Failures(ctx, ipKey(ip)) >= int64(threshold)
+}
@@ -0,0 +1,138 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/challenge"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
+	"go.uber.org/zap"
+)
+
+type memoryAttempts struct {
+	failures map[string]int64
+}
+
+func (m *memoryAttempts) LockedFor(context.Context, string) (time.Duration, error) { return 0, nil }
+
+func (m *memoryAttempts) Failures(_ context.Context, key string) (int64, error) {
+	return m.failures[key], nil
+}
+
+func (m *memoryAttempts) RecordFailure(_ context.Context, key string, _ time.Duration) (int64, error) {
+	m.failures[key]++
+	return m.failures[key], nil
+}
+
+func (m *memoryAttempts) Lock(context.Context, string, time.Duration) error { return nil }
+
+func (m *memoryAttempts) Reset(_ context.Context, key string) error {
+	delete(m.failures, key)
+	return nil
+}
+
+type memoryDevices struct {
+	devices map[uuid.UUID][]KnownDevice
+}
+
+func (m *memoryDevices) FindByPerson(_ context.Context, personId uuid.UUID) ([]KnownDevice, error) {
+	return m.devices[personId], nil
+}
+
+func (m *memoryDevices) Save(_ context.Context, device *KnownDevice) error {
+	m.devices[device.PersonId] = append(m.devices[device.PersonId], *device)
+	return nil
+}
+
+func (m *memoryDevices) Delete(context.Context, uuid.UUID, string) error { return nil }
+
+const fakeAnswer = "let-me-in"
+
+func newChallengeUseCase(conf *pkg.Config, devices *memoryDevices) *UseCase {
+	logger := zap.NewNop().Sugar()
+	return &UseCase{
+		conf:       conf,
+		logger:     logger,
+		guard:      NewLoginGuard(&memoryAttempts{failures: make(map[string]int64)}, nil, conf, logger),
+		monitor:    NewLoginMonitor(devices, nil, nil, conf, logger),
+		challenges: challenge.NewFake(fakeAnswer),
+	}
+}
+
+func TestLoginRiskyNewNetworks(t *testing.T) {
+	ctx := context.Background()
+	known := uuid.New()
+	devices := &memoryDevices{devices: map[uuid.UUID][]KnownDevice{
+		known: {{PersonId: known, IPPrefix: ipPrefix("198.51.100.7")}},
+	}}
+	u := newChallengeUseCase(&pkg.Config{ChallengeNewNetworks: true}, devices)
+
+	tests := []struct {
+		name     string
+		personId uuid.UUID
+		ip       string
+		want     bool
+	}{
+		{"known network", known, "198.51.100.200", false},
+		{"new network", known, "203.0.113.9", true},
+		{"first login", uuid.New(), "198.51.100.7", true},
+		{"unknown username", uuid.Nil, "198.51.100.7", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := u.loginRisky(ctx, tt.personId, tt.ip)
+			if got != tt.want {
+				t.Errorf("loginRisky() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginRiskyAfterFailures(t *testing.T) {
+	ctx := context.Background()
+	u := newChallengeUseCase(&pkg.Config{ChallengeAfterFailures: 2}, &memoryDevices{})
+	ip := ipKey("198.51.100.7")
+
+	for i := 0; i < 2; i++ {
+		if u.loginRisky(ctx, uuid.Nil, "198.51.100.7", ip) {
+			t.Fatalf("risky after %d failures", i)
+		}
+		u.guard.Fail(ctx, ip)
+	}
+	if !u.loginRisky(ctx, uuid.Nil, "198.51.100.7", ip) {
+		t.Fatal("not risky after reaching the threshold")
+	}
+}
+
+func TestRequireChallenge(t *testing.T) {
+	ctx := context.Background()
+	u := newChallengeUseCase(&pkg.Config{}, &memoryDevices{})
+
+	if err := u.requireChallenge(ctx, false, "", "198.51.100.7"); err != nil {
+		t.Fatalf("requireChallenge() without risk = %v", err)
+	}
+
+	var challengeErr *ChallengeError
+	err := u.requireChallenge(ctx, true, "", "198.51.100.7")
+	if !errors.As(err, &challengeErr) || challengeErr.Rejected != nil {
+		t.Fatalf("requireChallenge() without answer = %v, want a fresh challenge", err)
+	}
+	if challengeErr.Challenge.Provider != challenge.ProviderFake {
+		t.Errorf("provider = %q, want %q", challengeErr.Challenge.Provider, challenge.ProviderFake)
+	}
+
+	err = u.requireChallenge(ctx, true, "wrong", "198.51.100.7")
+	if !errors.As(err, &challengeErr) || !errors.Is(challengeErr.Rejected, challenge.ErrChallengeFailed) {
+		t.Fatalf("requireChallenge() with a wrong answer = %v, want a rejection", err)
+	}
+	if !errors.Is(err, ErrChallengeRequired) {
+		t.Errorf("%v does not match ErrChallengeRequired", err)
+	}
+
+	if err := u.requireChallenge(ctx, true, fakeAnswer, "198.51.100.7"); err != nil {
+		t.Fatalf("requireChallenge() with the answer = %v", err)
+	}
+}
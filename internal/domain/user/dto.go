@@ -2,6 +2,7 @@ package user
 
 import (
 	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/passkey"
 	"github.com/oyamo/forumz-auth-server/internal/pkg"
 	"time"
 )
@@ -11,8 +12,13 @@ type RegistrationRequest struct {
 	LastName     string   `json:"lastName" validate:"required"`
 	EmailAddress string   `json:"emailAddress" validate:"required,email"`
 	Username     string   `json:"username" validate:"required"`
-	Password     string   `json:"password" validate:"required,min=8,max=16"`
+	Password     string   `json:"password" validate:"required"`
 	Dob          pkg.Date `json:"dob" validate:"required"`
+	InviteCode   string   `json:"inviteCode"`
+	// Challenge answers the challenge of a previous attempt, see
+	// ChallengeError.
+	Challenge string `json:"challenge"`
+	ClientIP  string `json:"-"`
 }
 
 type UpdateInfoRequest struct {
@@ -42,11 +48,16 @@ type UserInfo struct {
 	Dob             time.Time `json:"dob"`
 	DatetimeCreated time.Time `json:"datetimeCreated"`
 	LastModified    time.Time `json:"lastModified"`
+	// Followers and Following are filled in by the profile endpoint.
+	Followers int64 `json:"followers"`
+	Following int64 `json:"following"`
 }
 
 type LoginRequest struct {
-	Username string `json:"username" validate:"required"`
-	Password string `json:"password" validate:"required,min=8,max=16"`
+	Username  string `json:"username" validate:"required"`
+	Password  string `json:"password" validate:"required,max=1024"`
+	Challenge string `json:"challenge"`
+	ClientIP  string `json:"-"`
 }
 
 type Token struct {
@@ -54,3 +65,84 @@ type Token struct {
 	ExpiresIn   int64     `json:"expiresIn"`
 	Sub         uuid.UUID `json:"sub"`
 }
+
+type MFAChallenge struct {
+	ChallengeToken string   `json:"challengeToken"`
+	ExpiresIn      int64    `json:"expiresIn"`
+	Methods        []string `json:"methods"`
+}
+
+type MFALoginRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	Code           string `json:"code" validate:"required,min=6,max=16"`
+	ClientIP       string `json:"-"`
+}
+
+type MFAWebAuthnOptionsRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+}
+
+type MFAWebAuthnLoginRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	passkey.AssertionRequest
+	ClientIP string `json:"-"`
+}
+
+type MagicLinkRequest struct {
+	Username string `json:"username" validate:"required"`
+}
+
+type ConsumeMagicLinkRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// MagicLink is the result of a magic link request. Nonce must be stored in
+// the requesting browser; Link and Recipient are empty when no account
+// matched so callers can respond identically either way.
+type MagicLink struct {
+	Nonce     string
+	Link      string
+	Recipient string
+	ExpiresAt time.Time
+}
+
+type ChangePasswordRequest struct {
+	Id              uuid.UUID `json:"-"`
+	CurrentPassword string    `json:"currentPassword" validate:"required,max=1024"`
+	NewPassword     string    `json:"newPassword" validate:"required"`
+}
+
+type PasswordResetRequest struct {
+	Username string `json:"username" validate:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type IntrospectionRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// Introspection describes an access token. Inactive tokens carry no other
+// fields.
+type Introspection struct {
+	Active bool       `json:"active"`
+	Sub    *uuid.UUID `json:"sub,omitempty"`
+	Exp    int64      `json:"exp,omitempty"`
+	Iat    int64      `json:"iat,omitempty"`
+	Jti    string     `json:"jti,omitempty"`
+}
+
+type RevokeSessionsRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// PasswordReset is the result of a password reset request. Link and
+// Recipient are empty when no account matched.
+type PasswordReset struct {
+	Link      string
+	Recipient string
+	ExpiresAt time.Time
+}
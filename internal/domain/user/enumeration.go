@@ -0,0 +1,74 @@
+package user
+
+import (
+	"context"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// UniformResponses reports whether responses must not reveal if an account
+// exists. Handlers use it to answer registration conflicts like successes.
+func (u *UseCase) UniformResponses() bool {
+	return u.conf.UniformAuthResponses
+}
+
+// failUnknownUser handles a login for a username that does not exist so that
+// it cannot be told apart from a wrong password: the password is compared
+// against a dummy hash and failures are counted and locked per username,
+// exactly like failures for a real account.
+func (u *UseCase) failUnknownUser(ctx context.Context, dto *LoginRequest, ip attemptKey) error {
+	span := trace.SpanFromContext(ctx)
+
+	account := accountKey("username:" + dto.Username)
+	err := u.guard.Check(ctx, account)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	span.AddEvent("ComparePasswordAndHash")
+	_, err = pkg.ComparePasswordAndHash(dto.Password, u.getDummyHash())
+	if err != nil {
+		u.logger.Errorw("error while comparing dummy password", "error", err)
+	}
+
+	u.guard.Fail(ctx, account, ip)
+	span.SetStatus(codes.Error, ErrIncorrectCredentials.Error())
+	return ErrIncorrectCredentials
+}
+
+// equalizeTiming spends the time of hashing password when registration is
+// rejected early, so conflicts take as long as successful registrations.
+func (u *UseCase) equalizeTiming(password string) {
+	if !u.conf.UniformAuthResponses {
+		return
+	}
+
+	_, err := pkg.HashPasswordWithParams(password, u.conf.PasswordHashParams)
+	if err != nil {
+		u.logger.Errorw("error while hashing password", "error", err)
+	}
+}
+
+// getDummyHash returns a hash made with the configured parameters, so that
+// comparing against it costs as much as comparing against a real one.
+func (u *UseCase) getDummyHash() string {
+	u.dummyHashOnce.Do(func() {
+		hash, err := pkg.HashPasswordWithParams(generateDummyPassword(), u.conf.PasswordHashParams)
+		if err != nil {
+			u.logger.Errorw("error while creating dummy hash", "error", err)
+			return
+		}
+		u.dummyHash = hash
+	})
+	return u.dummyHash
+}
+
+func generateDummyPassword() string {
+	nonce, err := generateNonce()
+	if err != nil {
+		return "dummy-password"
+	}
+	return nonce
+}
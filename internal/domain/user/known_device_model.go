@@ -0,0 +1,21 @@
+package user
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// KnownDevice is a client a person has logged in from before, identified by
+// a fingerprint of its user agent, IP prefix and country.
+type KnownDevice struct {
+	PersonId        uuid.UUID
+	Fingerprint     string
+	UserAgent       string
+	IPPrefix        string
+	Country         string
+	Latitude        float64
+	Longitude       float64
+	HasCoordinates  bool
+	DatetimeCreated time.Time
+	LastSeen        time.Time
+}
@@ -0,0 +1,134 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/audit"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"net/url"
+	"time"
+)
+
+// observeLogin remembers the device of a successful login and tells
+// the person about it when it is unfamiliar. Failures are logged only, they
+// must not stop the login.
+func (u *UseCase) observeLogin(ctx context.Context, personId uuid.UUID) {
+	span := trace.SpanFromContext(ctx)
+
+	if !u.conf.LoginAlerts {
+		return
+	}
+
+	ip, userAgent := audit.Client(ctx)
+	span.AddEvent("u.monitor.Assess")
+	assessment, err := u.monitor.Assess(ctx, personId, ip, userAgent)
+	if err != nil {
+		u.logger.Errorw("error while assessing login", "error", err)
+		span.RecordError(err)
+		return
+	}
+
+	if !assessment.Unfamiliar() {
+		return
+	}
+
+	span.AddEvent("u.personRepository.Find")
+	person, err := u.personRepository.Find(ctx, personId)
+	if err != nil {
+		u.logger.Errorw("error while finding user", "error", err)
+		span.RecordError(err)
+		return
+	}
+
+	link, err := u.revokeLink(person.Id, assessment.Device.Fingerprint)
+	if err != nil {
+		u.logger.Errorw("error while creating sign out link", "error", err)
+		span.RecordError(err)
+		return
+	}
+
+	u.logger.Infow("unfamiliar login", "personId", person.Id, "reasons", assessment.Reasons)
+	u.monitor.Alert(person, assessment, link)
+}
+
+// revokeLink builds the "this wasn't me" link sent with login alerts.
+func (u *UseCase) revokeLink(personId uuid.UUID, fingerprint string) (string, error) {
+	claims := jwt.MapClaims{
+		"sub": personId,
+		"exp": time.Now().Add(u.conf.LoginAlertTTL).Unix(),
+		"iat": time.Now().Unix(),
+		"nbf": time.Now().Unix(),
+		"iss": tokenIssuer,
+		"jti": uuid.New().String(),
+		"typ": TokenTypeRevokeSessions,
+		"fpr": fingerprint,
+	}
+
+	encoded, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(u.privateKey)
+	if err != nil {
+		return "", fmt.Errorf("create: sign revoke token: %w", err)
+	}
+
+	link, err := url.Parse(u.conf.LoginAlertURL)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set("token", encoded)
+	link.RawQuery = query.Encode()
+
+	return link.String(), nil
+}
+
+// RevokeSessions signs out every session of the person a login alert was
+// sent to and forgets the device the alert was about. It returns the id of
+// the person.
+func (u *UseCase) RevokeSessions(ctx context.Context, dto *RevokeSessionsRequest) (personId uuid.UUID, err error) {
+	span := trace.SpanFromContext(ctx)
+
+	defer func() {
+		u.auditAction(ctx, audit.ActionSessionsRevoke, personId, err)
+	}()
+
+	span.AddEvent("parseClaims")
+	claims, err := u.parseClaims(dto.Token, TokenTypeRevokeSessions)
+	if err != nil {
+		span.SetStatus(codes.Error, ErrInvalidRevokeLink.Error())
+		return uuid.Nil, errors.Join(ErrInvalidRevokeLink, err)
+	}
+
+	sub, err := claims.GetSubject()
+	if err != nil {
+		return uuid.Nil, errors.Join(ErrInvalidRevokeLink, err)
+	}
+
+	personId, err = uuid.Parse(sub)
+	if err != nil {
+		return uuid.Nil, errors.Join(ErrInvalidRevokeLink, err)
+	}
+
+	span.AddEvent("u.sessions.RevokeAll")
+	err = u.sessions.RevokeAll(ctx, personId, time.Now(), accessTokenTTL)
+	if err != nil {
+		u.logger.Errorw("error while revoking sessions", "error", err)
+		span.RecordError(err)
+		return personId, err
+	}
+
+	if fingerprint, _ := claims["fpr"].(string); fingerprint != "" {
+		span.AddEvent("u.monitor.Forget")
+		err = u.monitor.Forget(ctx, personId, fingerprint)
+		if err != nil {
+			// The sessions are gone already, which is what matters.
+			u.logger.Errorw("error while forgetting device", "error", err)
+			span.RecordError(err)
+		}
+	}
+
+	span.SetStatus(codes.Ok, "success")
+	return personId, nil
+}
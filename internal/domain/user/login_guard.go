@@ -0,0 +1,209 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
+	"go.uber.org/zap"
+	"math"
+	"strings"
+	"time"
+)
+
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+
+// LockoutError is returned while an account or client IP is locked out. It
+// matches ErrTooManyAttempts with errors.Is.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockoutError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// Lockout scopes. Accounts are keyed by person id when the username is known
+// so that alternating between username and email does not help an attacker.
+const (
+	ScopeAccount = "account"
+	ScopeIP      = "ip"
+	// ScopeRegistration counts registrations per IP block. It is never
+	// locked, only used to decide when registrations need a challenge.
+	ScopeRegistration = "registration"
+)
+
+const lockoutTopic = "Put-Login-Lockout-v1"
+
+type attemptKey struct {
+	scope string
+	id    string
+}
+
+func (k attemptKey) String() string {
+	return k.scope + "-" + k.id
+}
+
+func accountKey(id string) attemptKey {
+	return attemptKey{scope: ScopeAccount, id: strings.ToLower(id)}
+}
+
+func ipKey(ip string) attemptKey {
+	return attemptKey{scope: ScopeIP, id: ip}
+}
+
+func registrationKey(ip string) attemptKey {
+	return attemptKey{scope: ScopeRegistration, id: ipPrefix(ip)}
+}
+
+// LoginGuard throttles password and second factor guessing. Every failure
+// increments a counter per account and per client IP; once a counter reaches
+// its threshold the key is locked for an exponentially growing period.
+type LoginGuard struct {
+	attempts LoginAttemptRepository
+	sender   EventSender
+	conf     *pkg.Config
+	logger   *zap.SugaredLogger
+}
+
+// Check returns a *LockoutError when any of keys is currently locked.
+func (g *LoginGuard) Check(ctx context.Context, keys ...attemptKey) error {
+	var longest time.Duration
+	for _, key := range keys {
+		if key.id == "" {
+			continue
+		}
+
+		d, err := g.attempts.LockedFor(ctx, key.String())
+		if err != nil {
+			return err
+		}
+
+		if d > longest {
+			longest = d
+		}
+	}
+
+	if longest > 0 {
+		return &LockoutError{RetryAfter: longest}
+	}
+	return nil
+}
+
+// Fail records a failed attempt against every key and locks the keys that
+// reached their threshold.
+func (g *LoginGuard) Fail(ctx context.Context, keys ...attemptKey) {
+	for _, key := range keys {
+		if key.id == "" {
+			continue
+		}
+
+		failures, err := g.attempts.RecordFailure(ctx, key.String(), g.conf.LoginFailureWindow)
+		if err != nil {
+			g.logger.Errorw("error while recording failed login", "error", err)
+			continue
+		}
+
+		threshold := g.threshold(key.scope)
+		if threshold <= 0 || failures < int64(threshold) {
+			continue
+		}
+
+		d := g.backoff(failures - int64(threshold))
+		err = g.attempts.Lock(ctx, key.String(), d)
+		if err != nil {
+			g.logger.Errorw("error while locking login", "error", err)
+			continue
+		}
+
+		g.logger.Warnw("login locked", "scope", key.scope, "key", key.id, "failures", failures, "duration", d)
+		event := map[string]interface{}{
+			"datetimeCreated": time.Now(),
+			"scope":           key.scope,
+			"key":             key.id,
+			"failures":        failures,
+			"lockedUntil":     time.Now().Add(d),
+		}
+		err = g.sender.Send(lockoutTopic, event)
+		if err != nil {
+			g.logger.Error(err)
+		}
+	}
+}
+
+// Succeed clears the counters of keys after a successful login.
+func (g *LoginGuard) Succeed(ctx context.Context, keys ...attemptKey) {
+	for _, key := range keys {
+		if key.id == "" {
+			continue
+		}
+
+		err := g.attempts.Reset(ctx, key.String())
+		if err != nil {
+			g.logger.Errorw("error while resetting failed logins", "error", err)
+		}
+	}
+}
+
+// Failures returns the highest failure count among keys.
+func (g *LoginGuard) Failures(ctx context.Context, keys ...attemptKey) int64 {
+	var highest int64
+	for _, key := range keys {
+		if key.id == "" {
+			continue
+		}
+
+		failures, err := g.attempts.Failures(ctx, key.String())
+		if err != nil {
+			g.logger.Errorw("error while reading failed logins", "error", err)
+			continue
+		}
+		highest = max(highest, failures)
+	}
+	return highest
+}
+
+// Count records an attempt against key without ever locking it and returns
+// the number of attempts within window of each other.
+func (g *LoginGuard) Count(ctx context.Context, key attemptKey, window time.Duration) int64 {
+	if key.id == "" {
+		return 0
+	}
+
+	count, err := g.attempts.RecordFailure(ctx, key.String(), window)
+	if err != nil {
+		g.logger.Errorw("error while counting attempts", "error", err)
+		return 0
+	}
+	return count
+}
+
+func (g *LoginGuard) threshold(scope string) int {
+	if scope == ScopeIP {
+		return g.conf.LoginMaxIPFailures
+	}
+	return g.conf.LoginMaxAccountFailures
+}
+
+// backoff doubles the lock for every failure past the threshold, starting
+// at LoginLockoutBase and capped at LoginLockoutMax.
+func (g *LoginGuard) backoff(excess int64) time.Duration {
+	d := float64(g.conf.LoginLockoutBase) * math.Pow(2, float64(excess))
+	if d > float64(g.conf.LoginLockoutMax) {
+		return g.conf.LoginLockoutMax
+	}
+	return time.Duration(d)
+}
+
+func NewLoginGuard(attempts LoginAttemptRepository, sender EventSender, conf *pkg.Config, logger *zap.SugaredLogger) *LoginGuard {
+	return &LoginGuard{
+		attempts: attempts,
+		sender:   sender,
+		conf:     conf,
+		logger:   logger,
+	}
+}
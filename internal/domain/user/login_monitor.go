@@ -0,0 +1,239 @@
+package user
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
+	"go.uber.org/zap"
+	"math"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Reasons a login is reported as unfamiliar.
+const (
+	LoginReasonNewDevice        = "new_device"
+	LoginReasonNewNetwork       = "new_network"
+	LoginReasonNewCountry       = "new_country"
+	LoginReasonImpossibleTravel = "impossible_travel"
+)
+
+const (
+	newLoginNotification = "NewLogin"
+	maxUserAgentLength   = 512
+	// Moves shorter than this are put down to GeoIP inaccuracy rather than
+	// travel.
+	minTravelDistanceKm = 500
+)
+
+// GeoLocator is satisfied by *pkg.GeoIPFile.
+type GeoLocator interface {
+	Locate(ip netip.Addr) (*pkg.GeoLocation, bool)
+}
+
+// LoginAssessment compares a login to the devices its person used before.
+type LoginAssessment struct {
+	Device  *KnownDevice
+	Reasons []string
+}
+
+// Unfamiliar reports whether the person should be told about the login.
+func (a *LoginAssessment) Unfamiliar() bool {
+	return len(a.Reasons) > 0
+}
+
+// LoginMonitor fingerprints successful logins by user agent, IP prefix and,
+// when a GeoIP database is configured, country. Logins from an unseen
+// fingerprint, or from too far away to have travelled since the previous
+// login, are reported to the person with a NewLogin notification.
+type LoginMonitor struct {
+	devices KnownDeviceRepository
+	geo     GeoLocator
+	sender  EventSender
+	conf    *pkg.Config
+	logger  *zap.SugaredLogger
+}
+
+// Assess fingerprints a login of personId from ip and userAgent, compares it
+// to the known devices of the person and remembers it. The first login of a
+// person is never unfamiliar.
+func (m *LoginMonitor) Assess(ctx context.Context, personId uuid.UUID, ip, userAgent string) (*LoginAssessment, error) {
+	device := m.fingerprint(personId, ip, userAgent)
+
+	known, err := m.devices.FindByPerson(ctx, personId)
+	if err != nil {
+		return nil, err
+	}
+
+	assessment := &LoginAssessment{
+		Device: device,
+	}
+	if len(known) > 0 {
+		assessment.Reasons = m.compare(device, known)
+	}
+
+	err = m.devices.Save(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+
+	return assessment, nil
+}
+
+// NewNetwork reports whether personId has no known device in the IP block of
+// ip, which includes people who never logged in.
+func (m *LoginMonitor) NewNetwork(ctx context.Context, personId uuid.UUID, ip string) (bool, error) {
+	known, err := m.devices.FindByPerson(ctx, personId)
+	if err != nil {
+		return false, err
+	}
+
+	prefix := ipPrefix(ip)
+	for _, device := range known {
+		if device.IPPrefix == prefix {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Forget removes a device, so that the next login from it is reported again.
+func (m *LoginMonitor) Forget(ctx context.Context, personId uuid.UUID, fingerprint string) error {
+	return m.devices.Delete(ctx, personId, fingerprint)
+}
+
+// Alert sends person a NewLogin notification for assessment. revokeLink
+// signs out every session of the person when they did not log in themselves.
+func (m *LoginMonitor) Alert(person *Person, assessment *LoginAssessment, revokeLink string) {
+	notification := map[string]interface{}{
+		"datetimeCreated": time.Now(),
+		"recipient":       person.EmailAddress,
+		"type":            newLoginNotification,
+		"additionalInfo": map[string]interface{}{
+			"userAgent":  assessment.Device.UserAgent,
+			"ipPrefix":   assessment.Device.IPPrefix,
+			"country":    assessment.Device.Country,
+			"reasons":    assessment.Reasons,
+			"revokeLink": revokeLink,
+		},
+	}
+
+	err := m.sender.Send("Put-Notification-v1", notification)
+	if err != nil {
+		m.logger.Error(err)
+	}
+}
+
+func (m *LoginMonitor) fingerprint(personId uuid.UUID, ip, userAgent string) *KnownDevice {
+	userAgent = strings.ToValidUTF8(userAgent, "")
+	if len(userAgent) > maxUserAgentLength {
+		// Cutting may split the last rune, which is dropped.
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+
+	device := &KnownDevice{
+		PersonId:  personId,
+		UserAgent: userAgent,
+	}
+
+	device.IPPrefix = ipPrefix(ip)
+	if addr, err := netip.ParseAddr(ip); err == nil && m.geo != nil {
+		if location, ok := m.geo.Locate(addr); ok {
+			device.Country = location.Country
+			device.Latitude = location.Latitude
+			device.Longitude = location.Longitude
+			device.HasCoordinates = location.HasCoordinates
+		}
+	}
+
+	sum := sha256.Sum256([]byte(device.UserAgent + "\n" + device.IPPrefix + "\n" + device.Country))
+	device.Fingerprint = hex.EncodeToString(sum[:])
+	return device
+}
+
+// ipPrefix returns the /24 of an IPv4 or the /48 of an IPv6 address, the
+// block a single network or subscriber usually gets. Unparseable addresses
+// are returned as they are.
+func ipPrefix(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+
+	addr = addr.Unmap()
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
+
+// compare returns the reasons device is unfamiliar next to known, which is
+// ordered by last seen, newest first.
+func (m *LoginMonitor) compare(device *KnownDevice, known []KnownDevice) []string {
+	var sameDevice, sameUserAgent, sameNetwork, sameCountry bool
+	for _, k := range known {
+		sameDevice = sameDevice || k.Fingerprint == device.Fingerprint
+		sameUserAgent = sameUserAgent || k.UserAgent == device.UserAgent
+		sameNetwork = sameNetwork || k.IPPrefix == device.IPPrefix
+		sameCountry = sameCountry || k.Country == device.Country
+	}
+
+	var reasons []string
+	if !sameDevice {
+		if !sameUserAgent {
+			reasons = append(reasons, LoginReasonNewDevice)
+		}
+		if !sameNetwork {
+			reasons = append(reasons, LoginReasonNewNetwork)
+		}
+		if device.Country != "" && !sameCountry {
+			reasons = append(reasons, LoginReasonNewCountry)
+		}
+		if len(reasons) == 0 {
+			// Every part was seen before, but never together.
+			reasons = append(reasons, LoginReasonNewDevice)
+		}
+	}
+
+	if m.impossibleTravel(device, &known[0]) {
+		reasons = append(reasons, LoginReasonImpossibleTravel)
+	}
+	return reasons
+}
+
+// impossibleTravel reports whether getting from the previous login to device
+// would have required moving faster than conf.LoginMaxTravelSpeed.
+func (m *LoginMonitor) impossibleTravel(device, previous *KnownDevice) bool {
+	if m.conf.LoginMaxTravelSpeed <= 0 || !device.HasCoordinates || !previous.HasCoordinates {
+		return false
+	}
+
+	distance := pkg.DistanceKm(
+		&pkg.GeoLocation{Latitude: previous.Latitude, Longitude: previous.Longitude},
+		&pkg.GeoLocation{Latitude: device.Latitude, Longitude: device.Longitude},
+	)
+	if distance < minTravelDistanceKm {
+		return false
+	}
+
+	hours := math.Max(time.Since(previous.LastSeen).Hours(), time.Minute.Hours())
+	return distance/hours > float64(m.conf.LoginMaxTravelSpeed)
+}
+
+func NewLoginMonitor(devices KnownDeviceRepository, geo GeoLocator, sender EventSender, conf *pkg.Config, logger *zap.SugaredLogger) *LoginMonitor {
+	return &LoginMonitor{
+		devices: devices,
+		geo:     geo,
+		sender:  sender,
+		conf:    conf,
+		logger:  logger,
+	}
+}
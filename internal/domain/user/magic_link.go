@@ -0,0 +1,168 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"net/url"
+	"time"
+)
+
+const magicLinkNonceLength = 32
+
+// RequestMagicLink issues a single-use login link for the account matching
+// dto.Username. The link is bound to a nonce which the caller must keep in
+// the requesting browser; only the hash of the nonce is embedded in the link.
+func (u *UseCase) RequestMagicLink(ctx context.Context, dto *MagicLinkRequest) (*MagicLink, error) {
+	span := trace.SpanFromContext(ctx)
+
+	nonce, err := generateNonce()
+	if err != nil {
+		u.logger.Errorw("error while generating nonce", "error", err)
+		span.RecordError(err)
+		return nil, err
+	}
+
+	expiry := time.Now().Add(u.conf.MagicLinkTTL)
+	ret := &MagicLink{
+		Nonce:     nonce,
+		ExpiresAt: expiry,
+	}
+
+	span.AddEvent("u.personRepository.FindByUsername")
+	person, err := u.personRepository.FindByUsername(ctx, dto.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			span.SetStatus(codes.Ok, "no matching account")
+			return ret, nil
+		}
+		u.logger.Errorw("error while checking if user exists", "error", err)
+		span.RecordError(err)
+		return nil, err
+	}
+
+	jti := uuid.New().String()
+	claims := jwt.MapClaims{
+		"sub":   person.Id,
+		"exp":   expiry.Unix(),
+		"iat":   time.Now().Unix(),
+		"nbf":   time.Now().Unix(),
+		"iss":   tokenIssuer,
+		"jti":   jti,
+		"typ":   TokenTypeMagicLink,
+		"nonce": hashNonce(nonce),
+	}
+
+	span.AddEvent("SignedString")
+	encoded, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(u.privateKey)
+	if err != nil {
+		u.logger.Errorw("error while signing magic link", "error", err)
+		span.RecordError(err)
+		return nil, fmt.Errorf("create: sign magic link: %w", err)
+	}
+
+	span.AddEvent("u.magicLinks.Save")
+	err = u.magicLinks.Save(ctx, jti, u.conf.MagicLinkTTL)
+	if err != nil {
+		u.logger.Errorw("error while saving magic link", "error", err)
+		span.RecordError(err)
+		return nil, err
+	}
+
+	link, err := url.Parse(u.conf.MagicLinkURL)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	query := link.Query()
+	query.Set("token", encoded)
+	link.RawQuery = query.Encode()
+
+	ret.Link = link.String()
+	ret.Recipient = person.EmailAddress
+
+	span.SetStatus(codes.Ok, "success")
+	return ret, nil
+}
+
+// ConsumeMagicLink exchanges a magic link token for a login. nonce is the
+// value stored in the browser that requested the link. As with Login, an
+// MFAChallenge is returned instead of a token when a second factor is set.
+func (u *UseCase) ConsumeMagicLink(ctx context.Context, dto *ConsumeMagicLinkRequest, nonce string) (ret *Token, challenge *MFAChallenge, err error) {
+	span := trace.SpanFromContext(ctx)
+
+	var personId uuid.UUID
+	defer func() {
+		u.auditLogin(ctx, personId, loginMethodMagicLink, "", challenge, err)
+	}()
+
+	span.AddEvent("parseClaims")
+	claims, err := u.parseClaims(dto.Token, TokenTypeMagicLink)
+	if err != nil {
+		span.SetStatus(codes.Error, ErrInvalidMagicLink.Error())
+		return nil, nil, errors.Join(ErrInvalidMagicLink, err)
+	}
+
+	expected, _ := claims["nonce"].(string)
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(hashNonce(nonce))) != 1 {
+		span.SetStatus(codes.Error, ErrInvalidMagicLink.Error())
+		return nil, nil, errors.Join(ErrInvalidMagicLink, errors.New("nonce mismatch"))
+	}
+
+	sub, err := claims.GetSubject()
+	if err != nil {
+		return nil, nil, errors.Join(ErrInvalidMagicLink, err)
+	}
+
+	personId, err = uuid.Parse(sub)
+	if err != nil {
+		return nil, nil, errors.Join(ErrInvalidMagicLink, err)
+	}
+
+	jti, _ := claims["jti"].(string)
+
+	span.AddEvent("u.magicLinks.Consume")
+	unused, err := u.magicLinks.Consume(ctx, jti)
+	if err != nil {
+		u.logger.Errorw("error while consuming magic link", "error", err)
+		span.RecordError(err)
+		return nil, nil, err
+	}
+
+	if !unused {
+		span.SetStatus(codes.Error, ErrInvalidMagicLink.Error())
+		return nil, nil, ErrInvalidMagicLink
+	}
+
+	ret, challenge, err = u.completeLogin(ctx, personId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	span.SetStatus(codes.Ok, "success")
+	return ret, challenge, nil
+}
+
+func generateNonce() (string, error) {
+	b := make([]byte, magicLinkNonceLength)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashNonce(nonce string) string {
+	sum := sha256.Sum256([]byte(nonce))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,54 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/mfa"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DisableMFA turns off the second factor of personId after checking code.
+func (u *UseCase) DisableMFA(ctx context.Context, personId uuid.UUID, code string) error {
+	return u.guardCode(ctx, personId, func() error {
+		return u.mfaUC.Disable(ctx, personId, code)
+	})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of personId after
+// checking code.
+func (u *UseCase) RegenerateRecoveryCodes(ctx context.Context, personId uuid.UUID, code string) (ret *mfa.RecoveryCodesResponse, err error) {
+	err = u.guardCode(ctx, personId, func() error {
+		ret, err = u.mfaUC.RegenerateRecoveryCodes(ctx, personId, code)
+		return err
+	})
+	return ret, err
+}
+
+// guardCode runs fn, which checks a TOTP or recovery code of personId, under
+// the lockout of the account. Wrong codes count as failed logins, so that a
+// stolen access token is not enough to guess them.
+func (u *UseCase) guardCode(ctx context.Context, personId uuid.UUID, fn func() error) error {
+	span := trace.SpanFromContext(ctx)
+
+	account := accountKey(personId.String())
+	span.AddEvent("u.guard.Check")
+	err := u.guard.Check(ctx, account)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	err = fn()
+	if err != nil {
+		if errors.Is(err, mfa.ErrInvalidCode) {
+			u.guard.Fail(ctx, account)
+		}
+		span.RecordError(err)
+		return err
+	}
+
+	span.SetStatus(codes.Ok, "success")
+	return nil
+}
@@ -0,0 +1,315 @@
+package user
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/audit"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"net/url"
+	"time"
+)
+
+// ChangePassword replaces the password of dto.Id after checking the current
+// one. The new password must satisfy the password policy.
+func (u *UseCase) ChangePassword(ctx context.Context, dto *ChangePasswordRequest) (err error) {
+	span := trace.SpanFromContext(ctx)
+
+	defer func() {
+		u.auditAction(ctx, audit.ActionPasswordChange, dto.Id, err)
+	}()
+
+	span.AddEvent("u.personRepository.Find")
+	person, err := u.personRepository.Find(ctx, dto.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			span.SetStatus(codes.Error, ErrUserNotFound.Error())
+			return ErrUserNotFound
+		}
+		u.logger.Errorw("error while finding user", "error", err)
+		span.RecordError(err)
+		return err
+	}
+
+	account := accountKey(person.Id.String())
+	span.AddEvent("u.guard.Check")
+	err = u.guard.Check(ctx, account)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	span.AddEvent("ComparePasswordAndHash")
+	correct, err := pkg.ComparePasswordAndHash(dto.CurrentPassword, person.PasswordHash)
+	if err != nil {
+		u.logger.Errorw("error while comparing password", "error", err)
+		span.RecordError(err)
+		return err
+	}
+
+	if !correct {
+		u.guard.Fail(ctx, account)
+		span.SetStatus(codes.Error, ErrIncorrectCredentials.Error())
+		return ErrIncorrectCredentials
+	}
+
+	err = u.setPassword(ctx, person, dto.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	span.SetStatus(codes.Ok, "success")
+	return nil
+}
+
+// RequestPasswordReset issues a password reset link for the account matching
+// dto.Username. The link stops working once the password changes, so it can
+// be used only once.
+func (u *UseCase) RequestPasswordReset(ctx context.Context, dto *PasswordResetRequest) (*PasswordReset, error) {
+	span := trace.SpanFromContext(ctx)
+
+	expiry := time.Now().Add(u.conf.PasswordResetTTL)
+	ret := &PasswordReset{
+		ExpiresAt: expiry,
+	}
+
+	span.AddEvent("u.personRepository.FindByUsername")
+	person, err := u.personRepository.FindByUsername(ctx, dto.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			span.SetStatus(codes.Ok, "no matching account")
+			return ret, nil
+		}
+		u.logger.Errorw("error while checking if user exists", "error", err)
+		span.RecordError(err)
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{
+		"sub": person.Id,
+		"exp": expiry.Unix(),
+		"iat": time.Now().Unix(),
+		"nbf": time.Now().Unix(),
+		"iss": tokenIssuer,
+		"jti": uuid.New().String(),
+		"typ": TokenTypePasswordReset,
+		"pwd": hashNonce(person.PasswordHash),
+	}
+
+	span.AddEvent("SignedString")
+	encoded, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(u.privateKey)
+	if err != nil {
+		u.logger.Errorw("error while signing password reset token", "error", err)
+		span.RecordError(err)
+		return nil, fmt.Errorf("create: sign password reset token: %w", err)
+	}
+
+	link, err := url.Parse(u.conf.PasswordResetURL)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	query := link.Query()
+	query.Set("token", encoded)
+	link.RawQuery = query.Encode()
+
+	ret.Link = link.String()
+	ret.Recipient = person.EmailAddress
+
+	span.SetStatus(codes.Ok, "success")
+	return ret, nil
+}
+
+// ResetPassword sets a new password using a token from RequestPasswordReset
+// and returns the id of the account it belongs to.
+func (u *UseCase) ResetPassword(ctx context.Context, dto *ResetPasswordRequest) (personId uuid.UUID, err error) {
+	span := trace.SpanFromContext(ctx)
+
+	defer func() {
+		u.audit.Record(ctx, &audit.Event{
+			SubjectId: personId,
+			Action:    audit.ActionPasswordReset,
+			Outcome:   audit.Outcome(err),
+		})
+	}()
+
+	span.AddEvent("parseClaims")
+	claims, err := u.parseClaims(dto.Token, TokenTypePasswordReset)
+	if err != nil {
+		span.SetStatus(codes.Error, ErrInvalidPasswordReset.Error())
+		return uuid.Nil, errors.Join(ErrInvalidPasswordReset, err)
+	}
+
+	sub, err := claims.GetSubject()
+	if err != nil {
+		return uuid.Nil, errors.Join(ErrInvalidPasswordReset, err)
+	}
+
+	personId, err = uuid.Parse(sub)
+	if err != nil {
+		return uuid.Nil, errors.Join(ErrInvalidPasswordReset, err)
+	}
+
+	span.AddEvent("u.personRepository.Find")
+	person, err := u.personRepository.Find(ctx, personId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			span.SetStatus(codes.Error, ErrInvalidPasswordReset.Error())
+			return uuid.Nil, ErrInvalidPasswordReset
+		}
+		u.logger.Errorw("error while finding user", "error", err)
+		span.RecordError(err)
+		return uuid.Nil, err
+	}
+
+	expected, _ := claims["pwd"].(string)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(hashNonce(person.PasswordHash))) != 1 {
+		span.SetStatus(codes.Error, ErrInvalidPasswordReset.Error())
+		return uuid.Nil, errors.Join(ErrInvalidPasswordReset, errors.New("password changed since the link was issued"))
+	}
+
+	err = u.setPassword(ctx, person, dto.Password)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	span.SetStatus(codes.Ok, "success")
+	return person.Id, nil
+}
+
+// setPassword checks password against the password policy, then hashes and
+// stores it.
+func (u *UseCase) setPassword(ctx context.Context, person *Person, password string) error {
+	span := trace.SpanFromContext(ctx)
+
+	span.AddEvent("u.passwords.Evaluate")
+	err := u.passwords.Evaluate(ctx, password, person.Username, person.EmailAddress)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	err = u.checkPasswordHistory(ctx, person, password)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	span.AddEvent("HashPassword")
+	hashedPassword, err := pkg.HashPasswordWithParams(password, u.conf.PasswordHashParams)
+	if err != nil {
+		u.logger.Errorw("error while hashing password", "error", err)
+		span.RecordError(err)
+		return err
+	}
+
+	span.AddEvent("u.personRepository.UpdatePassword")
+	err = u.personRepository.UpdatePassword(ctx, person.Id, hashedPassword)
+	if err != nil {
+		u.logger.Errorw("error while updating password", "error", err)
+		span.RecordError(err)
+		return err
+	}
+
+	u.recordPassword(ctx, person.Id, hashedPassword)
+	return nil
+}
+
+// checkPasswordHistory rejects password when it matches the current password
+// or one of the previous ones, up to conf.PasswordHistory in total.
+func (u *UseCase) checkPasswordHistory(ctx context.Context, person *Person, password string) error {
+	span := trace.SpanFromContext(ctx)
+
+	if u.conf.PasswordHistory <= 0 {
+		return nil
+	}
+
+	span.AddEvent("u.passwordHistory.Recent")
+	recent, err := u.passwordHistory.Recent(ctx, person.Id, u.conf.PasswordHistory)
+	if err != nil {
+		u.logger.Errorw("error while reading password history", "error", err)
+		span.RecordError(err)
+		return err
+	}
+
+	// The current hash is usually the newest history entry, but imported
+	// accounts start without any history.
+	hashes := []string{person.PasswordHash}
+	for _, hash := range recent {
+		if hash != person.PasswordHash && len(hashes) < u.conf.PasswordHistory {
+			hashes = append(hashes, hash)
+		}
+	}
+
+	span.AddEvent("ComparePasswordAndHash")
+	for _, hash := range hashes {
+		reused, err := pkg.ComparePasswordAndHash(password, hash)
+		if err != nil {
+			u.logger.Errorw("error while comparing password history", "error", err)
+			span.RecordError(err)
+			continue
+		}
+
+		if reused {
+			return &PolicyError{Violations: []PolicyViolation{{
+				Code:    ViolationPasswordReused,
+				Field:   "password",
+				Message: fmt.Sprintf("password must differ from your last %d passwords", u.conf.PasswordHistory),
+			}}}
+		}
+	}
+
+	return nil
+}
+
+// recordPassword adds a newly set hash to the password history. Failures are
+// logged only, the password itself has already been stored.
+func (u *UseCase) recordPassword(ctx context.Context, personId uuid.UUID, passwordHash string) {
+	span := trace.SpanFromContext(ctx)
+
+	if u.conf.PasswordHistory <= 0 {
+		return
+	}
+
+	span.AddEvent("u.passwordHistory.Add")
+	err := u.passwordHistory.Add(ctx, personId, passwordHash, u.conf.PasswordHistory)
+	if err != nil {
+		u.logger.Errorw("error while recording password history", "error", err)
+		span.RecordError(err)
+	}
+}
+
+// upgradeHash rehashes password after a successful login when the stored hash
+// was made with weaker parameters than currently configured. Failures are
+// logged only, the login itself has already succeeded.
+func (u *UseCase) upgradeHash(ctx context.Context, person *Person, password string) {
+	span := trace.SpanFromContext(ctx)
+
+	if !pkg.NeedsRehash(person.PasswordHash, u.conf.PasswordHashParams) {
+		return
+	}
+
+	span.AddEvent("HashPassword")
+	hashedPassword, err := pkg.HashPasswordWithParams(password, u.conf.PasswordHashParams)
+	if err != nil {
+		u.logger.Errorw("error while rehashing password", "error", err)
+		span.RecordError(err)
+		return
+	}
+
+	span.AddEvent("u.personRepository.UpdatePassword")
+	err = u.personRepository.UpdatePassword(ctx, person.Id, hashedPassword)
+	if err != nil {
+		u.logger.Errorw("error while saving rehashed password", "error", err)
+		span.RecordError(err)
+		return
+	}
+
+	person.PasswordHash = hashedPassword
+}
@@ -0,0 +1,213 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password violation codes returned to clients in PolicyViolation.Code.
+const (
+	ViolationPasswordTooShort      = "password_too_short"
+	ViolationPasswordTooLong       = "password_too_long"
+	ViolationPasswordTooWeak       = "password_too_weak"
+	ViolationPasswordContainsIdent = "password_contains_identity"
+	ViolationPasswordBreached      = "password_breached"
+	ViolationPasswordReused        = "password_reused"
+)
+
+// Identifiers shorter than this are not checked for, "al" inside a password
+// says nothing about the account it belongs to.
+const minIdentifierLength = 3
+
+// PasswordPolicy decides whether a new password is acceptable. identifiers
+// are the username and email address of the account, which the password
+// must not contain. Implementations return a *PolicyError when the password
+// is rejected.
+type PasswordPolicy interface {
+	Evaluate(ctx context.Context, password string, identifiers ...string) error
+}
+
+// BreachedPasswords is satisfied by *pkg.PwnedPasswordFile.
+type BreachedPasswords interface {
+	Contains(password string) (bool, error)
+}
+
+type passwordPolicy struct {
+	minLength int
+	maxLength int
+	minScore  int
+	breached  BreachedPasswords
+}
+
+func (p *passwordPolicy) Evaluate(ctx context.Context, password string, identifiers ...string) error {
+	violations := p.check(password, identifiers)
+
+	if p.breached != nil && len(violations) == 0 {
+		found, err := p.breached.Contains(password)
+		if err != nil {
+			return fmt.Errorf("password policy: breach lookup: %w", err)
+		}
+		if found {
+			violations = append(violations, PolicyViolation{
+				Code:    ViolationPasswordBreached,
+				Field:   "password",
+				Message: "password has appeared in a data breach",
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+func (p *passwordPolicy) check(password string, identifiers []string) []PolicyViolation {
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		return []PolicyViolation{{
+			Code:    ViolationPasswordTooShort,
+			Field:   "password",
+			Message: fmt.Sprintf("password must be at least %d characters", p.minLength),
+		}}
+	}
+
+	if p.maxLength > 0 && length > p.maxLength {
+		return []PolicyViolation{{
+			Code:    ViolationPasswordTooLong,
+			Field:   "password",
+			Message: fmt.Sprintf("password must be at most %d characters", p.maxLength),
+		}}
+	}
+
+	var violations []PolicyViolation
+	if containsIdentifier(password, identifiers) {
+		violations = append(violations, PolicyViolation{
+			Code:    ViolationPasswordContainsIdent,
+			Field:   "password",
+			Message: "password must not contain your username or email address",
+		})
+	}
+
+	if PasswordScore(password) < p.minScore {
+		violations = append(violations, PolicyViolation{
+			Code:    ViolationPasswordTooWeak,
+			Field:   "password",
+			Message: "password is too easy to guess, use a longer password or mix more kinds of characters",
+		})
+	}
+	return violations
+}
+
+// PasswordScore rates password from 0 (trivial) to 4 (strong) by an entropy
+// estimate: the size of the character classes in use raised to the number of
+// characters, where characters repeating or continuing a run such as "aaa"
+// or "1234" only count for a fraction.
+func PasswordScore(password string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r <= unicode.MaxASCII && unicode.IsLower(r):
+			lower = true
+		case r <= unicode.MaxASCII && unicode.IsUpper(r):
+			upper = true
+		case r <= unicode.MaxASCII && unicode.IsDigit(r):
+			digit = true
+		case r <= unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	var effective float64
+	seen := make(map[rune]int)
+	prev, delta := rune(-1), rune(0)
+	for _, r := range password {
+		weight := 1.0
+		switch {
+		case r == prev || (prev >= 0 && r-prev == delta && (delta == 1 || delta == -1)):
+			// Repeats and ascending or descending runs.
+			weight = 0.25
+		case seen[unicode.ToLower(r)] > 0:
+			weight = 0.5
+		}
+		effective += weight
+
+		if prev >= 0 {
+			delta = r - prev
+		}
+		prev = r
+		seen[unicode.ToLower(r)]++
+	}
+
+	bits := effective * math.Log2(float64(pool))
+	switch {
+	case bits < 28:
+		return 0
+	case bits < 36:
+		return 1
+	case bits < 60:
+		return 2
+	case bits < 80:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// containsIdentifier reports whether password contains any identifier, or
+// the local part of an email address, ignoring case.
+func containsIdentifier(password string, identifiers []string) bool {
+	folded := strings.ToLower(password)
+	for _, identifier := range identifiers {
+		identifier = strings.ToLower(strings.TrimSpace(identifier))
+		candidates := []string{identifier}
+		if at := strings.LastIndex(identifier, "@"); at > 0 {
+			candidates = append(candidates, identifier[:at])
+		}
+
+		for _, candidate := range candidates {
+			if utf8.RuneCountInString(candidate) >= minIdentifierLength && strings.Contains(folded, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func NewPasswordPolicy(conf *pkg.Config) (PasswordPolicy, error) {
+	policy := &passwordPolicy{
+		minLength: conf.PasswordMinLength,
+		maxLength: conf.PasswordMaxLength,
+		minScore:  conf.PasswordMinScore,
+	}
+
+	if conf.PasswordBreachFile != "" {
+		breached, err := pkg.NewPwnedPasswordFile(conf.PasswordBreachFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.breached = breached
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,263 @@
+package user
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+//go:embed disposable_domains.txt
+var disposableDomainsFile string
+
+var ErrPolicyViolation = errors.New("registration policy violation")
+
+// Violation codes returned to clients in PolicyViolation.Code.
+const (
+	ViolationDobInFuture         = "dob_in_future"
+	ViolationUnderMinimumAge     = "under_minimum_age"
+	ViolationUsernameReserved    = "username_reserved"
+	ViolationUsernameTooShort    = "username_too_short"
+	ViolationUsernameTooLong     = "username_too_long"
+	ViolationUsernameInvalidChar = "username_invalid_character"
+	ViolationEmailInvalid        = "email_invalid"
+	ViolationEmailDomainBlocked  = "email_domain_blocked"
+	ViolationEmailDisposable     = "email_domain_disposable"
+)
+
+type PolicyViolation struct {
+	Code    string `json:"code"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// PolicyError carries every violation found for a request. It matches
+// ErrPolicyViolation with errors.Is.
+type PolicyError struct {
+	Violations []PolicyViolation
+}
+
+func (e *PolicyError) Error() string {
+	codes := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		codes = append(codes, v.Code)
+	}
+	return fmt.Sprintf("%s: %s", ErrPolicyViolation, strings.Join(codes, ", "))
+}
+
+func (e *PolicyError) Unwrap() error {
+	return ErrPolicyViolation
+}
+
+// RegistrationPolicy decides whether a registration request is acceptable.
+// Implementations return a *PolicyError when the request is rejected.
+type RegistrationPolicy interface {
+	Evaluate(ctx context.Context, dto *RegistrationRequest) error
+}
+
+type registrationPolicy struct {
+	minimumAge        int
+	reservedUsernames map[string]struct{}
+	usernameMinLength int
+	usernameMaxLength int
+	usernameCharset   string
+	blockedDomains    map[string]struct{}
+	disposableDomains map[string]struct{}
+	passwords         PasswordPolicy
+	now               func() time.Time
+}
+
+func (p *registrationPolicy) Evaluate(ctx context.Context, dto *RegistrationRequest) error {
+	var violations []PolicyViolation
+	violations = append(violations, p.checkDob(time.Time(dto.Dob))...)
+	violations = append(violations, p.checkUsername(dto.Username)...)
+	violations = append(violations, p.checkEmail(dto.EmailAddress)...)
+
+	err := p.passwords.Evaluate(ctx, dto.Password, dto.Username, dto.EmailAddress)
+	var passwordErr *PolicyError
+	switch {
+	case errors.As(err, &passwordErr):
+		violations = append(violations, passwordErr.Violations...)
+	case err != nil:
+		return err
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+func (p *registrationPolicy) checkDob(dob time.Time) []PolicyViolation {
+	now := p.now()
+	if dob.After(now) {
+		return []PolicyViolation{{
+			Code:    ViolationDobInFuture,
+			Field:   "dob",
+			Message: "date of birth is in the future",
+		}}
+	}
+
+	if p.minimumAge > 0 && age(dob, now) < p.minimumAge {
+		return []PolicyViolation{{
+			Code:    ViolationUnderMinimumAge,
+			Field:   "dob",
+			Message: fmt.Sprintf("must be at least %d years old", p.minimumAge),
+		}}
+	}
+	return nil
+}
+
+func (p *registrationPolicy) checkUsername(username string) []PolicyViolation {
+	var violations []PolicyViolation
+
+	length := utf8.RuneCountInString(username)
+	if length < p.usernameMinLength {
+		violations = append(violations, PolicyViolation{
+			Code:    ViolationUsernameTooShort,
+			Field:   "username",
+			Message: fmt.Sprintf("username must be at least %d characters", p.usernameMinLength),
+		})
+	}
+
+	if p.usernameMaxLength > 0 && length > p.usernameMaxLength {
+		violations = append(violations, PolicyViolation{
+			Code:    ViolationUsernameTooLong,
+			Field:   "username",
+			Message: fmt.Sprintf("username must be at most %d characters", p.usernameMaxLength),
+		})
+	}
+
+	if p.usernameCharset != "" {
+		for _, r := range username {
+			if !strings.ContainsRune(p.usernameCharset, r) {
+				violations = append(violations, PolicyViolation{
+					Code:    ViolationUsernameInvalidChar,
+					Field:   "username",
+					Message: fmt.Sprintf("username contains a disallowed character %q", r),
+				})
+				break
+			}
+		}
+	}
+
+	if _, reserved := p.reservedUsernames[normalizeUsername(username)]; reserved {
+		violations = append(violations, PolicyViolation{
+			Code:    ViolationUsernameReserved,
+			Field:   "username",
+			Message: "username is reserved",
+		})
+	}
+
+	return violations
+}
+
+func (p *registrationPolicy) checkEmail(email string) []PolicyViolation {
+	at := strings.LastIndex(email, "@")
+	if at < 0 || at == len(email)-1 {
+		return []PolicyViolation{{
+			Code:    ViolationEmailInvalid,
+			Field:   "emailAddress",
+			Message: "email address is invalid",
+		}}
+	}
+
+	domain := strings.ToLower(strings.TrimSuffix(email[at+1:], "."))
+	if matchDomain(p.blockedDomains, domain) {
+		return []PolicyViolation{{
+			Code:    ViolationEmailDomainBlocked,
+			Field:   "emailAddress",
+			Message: "email domain is not allowed",
+		}}
+	}
+
+	if matchDomain(p.disposableDomains, domain) {
+		return []PolicyViolation{{
+			Code:    ViolationEmailDisposable,
+			Field:   "emailAddress",
+			Message: "disposable email addresses are not allowed",
+		}}
+	}
+	return nil
+}
+
+// matchDomain reports whether domain or any of its parent domains is in set.
+func matchDomain(set map[string]struct{}, domain string) bool {
+	for domain != "" {
+		if _, ok := set[domain]; ok {
+			return true
+		}
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return false
+}
+
+// normalizeUsername folds case and drops separators so that "Ad_min" and
+// "admin." are treated like "admin" when checking reserved names.
+func normalizeUsername(username string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '_', '.', '-':
+			return -1
+		}
+		return r
+	}, strings.ToLower(username))
+}
+
+// age returns the number of full years between dob and now.
+func age(dob, now time.Time) int {
+	years := now.Year() - dob.Year()
+	if now.Month() < dob.Month() || (now.Month() == dob.Month() && now.Day() < dob.Day()) {
+		years--
+	}
+	return years
+}
+
+func toSet(items []string, normalize func(string) string) map[string]struct{} {
+	set := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		set[normalize(item)] = struct{}{}
+	}
+	return set
+}
+
+func parseDomainList(content string) []string {
+	var domains []string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, line)
+	}
+	return domains
+}
+
+func NewRegistrationPolicy(conf *pkg.Config, passwords PasswordPolicy) RegistrationPolicy {
+	var disposable map[string]struct{}
+	if conf.BlockDisposableEmails {
+		disposable = toSet(parseDomainList(disposableDomainsFile), strings.ToLower)
+	}
+
+	return &registrationPolicy{
+		minimumAge:        conf.MinimumAge,
+		reservedUsernames: toSet(conf.ReservedUsernames, normalizeUsername),
+		usernameMinLength: conf.UsernameMinLength,
+		usernameMaxLength: conf.UsernameMaxLength,
+		usernameCharset:   conf.UsernameCharset,
+		blockedDomains:    toSet(conf.BlockedEmailDomains, strings.ToLower),
+		disposableDomains: disposable,
+		passwords:         passwords,
+		now:               time.Now,
+	}
+}
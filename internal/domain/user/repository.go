@@ -3,10 +3,15 @@ package user
 import (
 	"context"
 	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/invite"
+	"time"
 )
 
 type PersonRepository interface {
 	Upsert(context.Context, *Person) error
+	// Create inserts a new person and, unless redemption is nil, records the
+	// invite they joined through in the same transaction.
+	Create(ctx context.Context, person *Person, redemption *invite.Redemption) error
 	UpdatePassword(context.Context, uuid.UUID, string) error
 	Find(context.Context, uuid.UUID) (*Person, error)
 	FindByUsername(context.Context, string) (*Person, error)
@@ -14,3 +19,59 @@ type PersonRepository interface {
 	ExistsByEmail(context.Context, string) (bool, error)
 	ExistsByUsername(context.Context, string) (bool, error)
 }
+
+// MagicLinkRepository tracks magic links that have been issued but not yet
+// used, making every link single-use.
+type MagicLinkRepository interface {
+	Save(ctx context.Context, id string, ttl time.Duration) error
+	// Consume removes id, returning false when it was unknown or expired.
+	Consume(ctx context.Context, id string) (bool, error)
+}
+
+// PasswordHistoryRepository keeps the most recent password hashes of each
+// person, newest first.
+type PasswordHistoryRepository interface {
+	Recent(ctx context.Context, personId uuid.UUID, n int) ([]string, error)
+	// Add records passwordHash and drops all but the keep most recent hashes.
+	Add(ctx context.Context, personId uuid.UUID, passwordHash string, keep int) error
+}
+
+// KnownDeviceRepository stores the devices each person has logged in from.
+type KnownDeviceRepository interface {
+	// FindByPerson returns the devices of personId, most recently seen first.
+	FindByPerson(ctx context.Context, personId uuid.UUID) ([]KnownDevice, error)
+	// Save inserts device, or refreshes its location and last seen time when
+	// the fingerprint is already known.
+	Save(ctx context.Context, device *KnownDevice) error
+	Delete(ctx context.Context, personId uuid.UUID, fingerprint string) error
+}
+
+// SessionRepository records when all sessions of a person were revoked.
+// Access tokens issued at or before that time are rejected.
+type SessionRepository interface {
+	// RevokeAll records at as the revocation time of personId, keeping it
+	// for ttl, the lifetime of the tokens it invalidates.
+	RevokeAll(ctx context.Context, personId uuid.UUID, at time.Time, ttl time.Duration) error
+	// RevokedAt returns the last revocation time, or the zero time.
+	RevokedAt(ctx context.Context, personId uuid.UUID) (time.Time, error)
+}
+
+// LoginAttemptRepository keeps failed login counters and temporary locks.
+// Keys are opaque to the repository.
+type LoginAttemptRepository interface {
+	// LockedFor returns how long key remains locked, or zero.
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	// Failures returns the failure counter of key, or zero.
+	Failures(ctx context.Context, key string) (int64, error)
+	// RecordFailure increments the failure counter of key, keeping it for
+	// window after the latest failure, and returns the new count.
+	RecordFailure(ctx context.Context, key string, window time.Duration) (int64, error)
+	Lock(ctx context.Context, key string, d time.Duration) error
+	Reset(ctx context.Context, key string) error
+}
+
+// EventSender publishes events to other services. It is satisfied by
+// *pkg.JSONSender.
+type EventSender interface {
+	Send(topic string, v any) error
+}
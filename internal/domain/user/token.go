@@ -0,0 +1,165 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+func (u *UseCase) issueToken(ctx context.Context, personId uuid.UUID) (*Token, error) {
+	span := trace.SpanFromContext(ctx)
+
+	expiry := time.Now().Add(accessTokenTTL)
+	iat := time.Now().Unix()
+	nbf := time.Now().Unix()
+
+	claims := jwt.MapClaims{
+		"sub": personId,
+		"exp": expiry.Unix(),
+		"iat": iat,
+		"nbf": nbf,
+		"iss": tokenIssuer,
+		"jti": uuid.New().String(),
+		"typ": TokenTypeAccess,
+	}
+
+	span.AddEvent("SignedString")
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	encoded, err := token.SignedString(u.privateKey)
+	if err != nil {
+		u.logger.Errorw("error while signing token", "error", err)
+		span.RecordError(err)
+		return nil, fmt.Errorf("create: sign token: %w", err)
+	}
+
+	expiresIn := expiry.Sub(time.Now())
+	return &Token{
+		AccessToken: encoded,
+		ExpiresIn:   int64(expiresIn.Seconds()),
+		Sub:         personId,
+	}, nil
+}
+
+func (u *UseCase) issueChallenge(ctx context.Context, personId uuid.UUID, methods []string) (*MFAChallenge, error) {
+	span := trace.SpanFromContext(ctx)
+
+	expiry := time.Now().Add(challengeTTL)
+	claims := jwt.MapClaims{
+		"sub": personId,
+		"exp": expiry.Unix(),
+		"iat": time.Now().Unix(),
+		"nbf": time.Now().Unix(),
+		"iss": tokenIssuer,
+		"jti": uuid.New().String(),
+		"typ": TokenTypeMFAChallenge,
+	}
+
+	span.AddEvent("SignedString")
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	encoded, err := token.SignedString(u.privateKey)
+	if err != nil {
+		u.logger.Errorw("error while signing challenge", "error", err)
+		span.RecordError(err)
+		return nil, fmt.Errorf("create: sign challenge: %w", err)
+	}
+
+	return &MFAChallenge{
+		ChallengeToken: encoded,
+		ExpiresIn:      int64(challengeTTL.Seconds()),
+		Methods:        methods,
+	}, nil
+}
+
+// parseToken verifies a token signed by this service and returns its
+// subject, rejecting tokens of any type other than typ.
+func (u *UseCase) parseToken(encoded, typ string) (uuid.UUID, error) {
+	claims, err := u.parseClaims(encoded, typ)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	sub, err := claims.GetSubject()
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return uuid.Parse(sub)
+}
+
+func (u *UseCase) parseClaims(encoded, typ string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()})).
+		ParseWithClaims(encoded, claims, func(token *jwt.Token) (interface{}, error) {
+			return u.publicKey, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	if claimTyp, _ := claims["typ"].(string); claimTyp != typ {
+		return nil, fmt.Errorf("unexpected token type %q", claimTyp)
+	}
+
+	return claims, nil
+}
+
+// Introspect reports whether an access token is valid and not revoked, for
+// services that cannot verify tokens themselves.
+func (u *UseCase) Introspect(ctx context.Context, dto *IntrospectionRequest) (*Introspection, error) {
+	span := trace.SpanFromContext(ctx)
+
+	inactive := &Introspection{Active: false}
+
+	span.AddEvent("parseClaims")
+	claims, err := u.parseClaims(dto.Token, TokenTypeAccess)
+	if err != nil {
+		span.SetStatus(codes.Ok, "inactive token")
+		return inactive, nil
+	}
+
+	sub, err := claims.GetSubject()
+	if err != nil {
+		return inactive, nil
+	}
+
+	personId, err := uuid.Parse(sub)
+	if err != nil {
+		return inactive, nil
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return inactive, nil
+	}
+
+	iat, err := claims.GetIssuedAt()
+	if err != nil || iat == nil {
+		return inactive, nil
+	}
+
+	span.AddEvent("u.sessions.RevokedAt")
+	revokedAt, err := u.sessions.RevokedAt(ctx, personId)
+	if err != nil {
+		u.logger.Errorw("error while checking session revocation", "error", err)
+		span.RecordError(err)
+		return nil, err
+	}
+	if !revokedAt.IsZero() && !iat.After(revokedAt) {
+		span.SetStatus(codes.Ok, "revoked token")
+		return inactive, nil
+	}
+
+	jti, _ := claims["jti"].(string)
+	span.SetStatus(codes.Ok, "success")
+	return &Introspection{
+		Active: true,
+		Sub:    &personId,
+		Exp:    exp.Unix(),
+		Iat:    iat.Unix(),
+		Jti:    jti,
+	}, nil
+}
@@ -6,12 +6,17 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/audit"
+	"github.com/oyamo/forumz-auth-server/internal/domain/challenge"
+	"github.com/oyamo/forumz-auth-server/internal/domain/invite"
+	"github.com/oyamo/forumz-auth-server/internal/domain/mfa"
+	"github.com/oyamo/forumz-auth-server/internal/domain/passkey"
 	"github.com/oyamo/forumz-auth-server/internal/pkg"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"sync"
 	"time"
 )
 
@@ -22,17 +27,100 @@ type UseCase struct {
 	conf             *pkg.Config
 	privateKey       *rsa.PrivateKey
 	publicKey        *rsa.PublicKey
+	policy           RegistrationPolicy
+	passwords        PasswordPolicy
+	passwordHistory  PasswordHistoryRepository
+	inviteUC         *invite.UseCase
+	mfaUC            *mfa.UseCase
+	passkeyUC        *passkey.UseCase
+	magicLinks       MagicLinkRepository
+	guard            *LoginGuard
+	audit            *audit.UseCase
+	monitor          *LoginMonitor
+	sessions         SessionRepository
+	challenges       challenge.Verifier
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
 var (
 	ErrUserNotFound         = errors.New("user not found")
 	ErrUserAlreadyExists    = errors.New("user already exists")
 	ErrIncorrectCredentials = errors.New("incorrect credentials")
+	ErrInvalidChallenge     = errors.New("invalid or expired mfa challenge")
+	ErrInvalidMagicLink     = errors.New("invalid, expired or already used magic link")
+	ErrInvalidPasswordReset = errors.New("invalid, expired or already used password reset link")
+	ErrInvalidRevokeLink    = errors.New("invalid or expired sign out link")
 )
 
-func (u *UseCase) Register(ctx context.Context, dto *RegistrationRequest) (*UpdateInfoResponse, error) {
+// ConflictError names the field that made a registration collide with an
+// existing account. It is joined with ErrUserAlreadyExists.
+type ConflictError struct {
+	Field string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s already exists", e.Field)
+}
+
+// Token types carried in the "typ" claim. AuthenticateRequest only accepts
+// access tokens; tokens issued before the claim existed have no type.
+const (
+	TokenTypeAccess         = "access"
+	TokenTypeMFAChallenge   = "mfa_challenge"
+	TokenTypeMagicLink      = "magic_link"
+	TokenTypePasswordReset  = "password_reset"
+	TokenTypeRevokeSessions = "revoke_sessions"
+)
+
+const (
+	accessTokenTTL    = time.Hour * 24
+	challengeTTL      = time.Minute * 5
+	tokenIssuer       = "http://localhost"
+	mfaMethodTOTP     = "totp"
+	mfaMethodBackup   = "recovery_code"
+	mfaMethodWebAuthn = "webauthn"
+)
+
+func (u *UseCase) Register(ctx context.Context, dto *RegistrationRequest) (ret *UpdateInfoResponse, err error) {
 	span := trace.SpanFromContext(ctx)
 
+	defer func() {
+		event := &audit.Event{
+			Action:  audit.ActionRegister,
+			Outcome: audit.Outcome(err),
+			Details: map[string]any{"username": dto.Username},
+		}
+		if ret != nil {
+			event.ActorId, event.SubjectId = ret.Id, ret.Id
+		}
+		u.audit.Record(ctx, event)
+	}()
+
+	err = u.requireChallenge(ctx, u.registrationRisky(ctx, dto.ClientIP), dto.Challenge, dto.ClientIP)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.AddEvent("u.policy.Evaluate")
+	err = u.policy.Evaluate(ctx, dto)
+	if err != nil {
+		span.SetStatus(codes.Error, ErrPolicyViolation.Error())
+		return nil, err
+	}
+
+	inviteRequired := u.inviteUC.Required()
+	if inviteRequired {
+		span.AddEvent("u.inviteUC.Check")
+		err = u.inviteUC.Check(ctx, dto.InviteCode)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
 	span.AddEvent("u.personRepository.ExistsByUsername")
 	exists, err := u.personRepository.ExistsByUsername(ctx, dto.Username)
 	if err != nil {
@@ -42,8 +130,8 @@ func (u *UseCase) Register(ctx context.Context, dto *RegistrationRequest) (*Upda
 	}
 
 	if exists {
-		err = errors.New("username already exists")
-		err = errors.Join(ErrUserAlreadyExists, err)
+		u.equalizeTiming(dto.Password)
+		err = errors.Join(ErrUserAlreadyExists, &ConflictError{Field: "username"})
 		span.RecordError(err)
 		return nil, err
 	}
@@ -57,15 +145,15 @@ func (u *UseCase) Register(ctx context.Context, dto *RegistrationRequest) (*Upda
 	}
 
 	if exists {
-		err = errors.New("email already exists")
-		err = errors.Join(ErrUserAlreadyExists, err)
+		u.equalizeTiming(dto.Password)
+		err = errors.Join(ErrUserAlreadyExists, &ConflictError{Field: "email"})
 		span.RecordError(err)
 		return nil, err
 	}
 
 	// hash password
 	span.AddEvent("HashPassword")
-	hashedPassword, err := pkg.HashPassword(dto.Password)
+	hashedPassword, err := pkg.HashPasswordWithParams(dto.Password, u.conf.PasswordHashParams)
 	if err != nil {
 		u.logger.Errorw("error while hashing password", "error", err)
 		span.RecordError(err)
@@ -90,14 +178,40 @@ func (u *UseCase) Register(ctx context.Context, dto *RegistrationRequest) (*Upda
 		Dob:          time.Time(dto.Dob),
 	}
 
-	span.AddEvent("u.personRepository.Upsert")
-	err = u.personRepository.Upsert(ctx, &person)
+	var claimed *invite.Invite
+	var redemption *invite.Redemption
+	if inviteRequired {
+		span.AddEvent("u.inviteUC.Claim")
+		claimed, err = u.inviteUC.Claim(ctx, dto.InviteCode)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		redemption = u.inviteUC.Redemption(claimed, person.Id)
+	}
+
+	// The person and the redemption are stored together, so a failure leaves
+	// neither and the claimed use is given back.
+	span.AddEvent("u.personRepository.Create")
+	err = u.personRepository.Create(ctx, &person, redemption)
 	if err != nil {
-		u.logger.Errorw("error while upserting user", "error", err)
+		u.logger.Errorw("error while creating user", "error", err)
 		span.RecordError(err)
+		if claimed != nil {
+			if releaseErr := u.inviteUC.Release(ctx, claimed); releaseErr != nil {
+				u.logger.Errorw("error while releasing invite", "error", releaseErr)
+			}
+		}
 		return nil, err
 	}
 
+	u.recordPassword(ctx, person.Id, hashedPassword)
+
+	if claimed != nil {
+		span.AddEvent("u.inviteUC.Redeem")
+		u.inviteUC.Redeem(ctx, claimed, person.Id)
+	}
+
 	span.AddEvent("u.redisRepository.Upsert")
 	err = u.redisRepository.Upsert(ctx, &person)
 	if err != nil {
@@ -106,7 +220,7 @@ func (u *UseCase) Register(ctx context.Context, dto *RegistrationRequest) (*Upda
 		return nil, err
 	}
 
-	ret := &UpdateInfoResponse{
+	ret = &UpdateInfoResponse{
 		Id:              person.Id,
 		FirstName:       person.FirstName,
 		LastName:        person.LastName,
@@ -121,20 +235,62 @@ func (u *UseCase) Register(ctx context.Context, dto *RegistrationRequest) (*Upda
 	return ret, nil
 }
 
-func (u *UseCase) Login(ctx context.Context, dto *LoginRequest) (*Token, error) {
+// Login checks the username and password. When the person has enabled
+// multi-factor authentication or registered a passkey no token is issued;
+// instead an MFAChallenge is returned which must be completed through
+// LoginMFA or LoginMFAWebAuthn.
+func (u *UseCase) Login(ctx context.Context, dto *LoginRequest) (ret *Token, challenge *MFAChallenge, err error) {
 	span := trace.SpanFromContext(ctx)
 
+	var subject uuid.UUID
+	defer func() {
+		u.auditLogin(ctx, subject, loginMethodPassword, dto.Username, challenge, err)
+	}()
+
+	ip := ipKey(dto.ClientIP)
+	span.AddEvent("u.guard.Check")
+	err = u.guard.Check(ctx, ip)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, nil, err
+	}
+
 	span.AddEvent("u.personRepository.FindByUsername")
 	user, err := u.personRepository.FindByUsername(ctx, dto.Username)
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			span.SetStatus(codes.Error, ErrUserNotFound.Error())
-			return nil, ErrUserNotFound
-		}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		u.logger.Errorw("error while checking if user exists", "error", err)
 		span.RecordError(err)
-		return nil, err
+		return nil, nil, err
+	}
+
+	// The challenge is decided before revealing whether the account exists;
+	// unknown usernames are counted under the same key failUnknownUser uses.
+	found := err == nil
+	account := accountKey("username:" + dto.Username)
+	if found {
+		subject = user.Id
+		account = accountKey(user.Id.String())
+	}
+
+	err = u.requireChallenge(ctx, u.loginRisky(ctx, subject, dto.ClientIP, account, ip), dto.Challenge, dto.ClientIP)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, nil, err
+	}
+
+	if !found {
+		if u.conf.UniformAuthResponses {
+			return nil, nil, u.failUnknownUser(ctx, dto, ip)
+		}
+		u.guard.Fail(ctx, ip)
+		span.SetStatus(codes.Error, ErrUserNotFound.Error())
+		return nil, nil, ErrUserNotFound
+	}
+
+	err = u.guard.Check(ctx, account)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, nil, err
 	}
 
 	span.AddEvent("ComparePasswordAndHash")
@@ -142,50 +298,243 @@ func (u *UseCase) Login(ctx context.Context, dto *LoginRequest) (*Token, error)
 	if err != nil {
 		u.logger.Errorw("error while comparing password", "error", err)
 		span.RecordError(err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	if !correct {
+		u.guard.Fail(ctx, account, ip)
 		span.SetStatus(codes.Error, ErrIncorrectCredentials.Error())
-		return nil, ErrIncorrectCredentials
+		return nil, nil, ErrIncorrectCredentials
+	}
+
+	u.upgradeHash(ctx, user, dto.Password)
+
+	ret, challenge, err = u.completeLogin(ctx, user.Id, account, ip)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	expiry := time.Now().Add(time.Hour * 24)
-	iat := time.Now().Unix()
-	nbf := time.Now().Unix()
+	span.SetStatus(codes.Ok, "success")
+	return ret, challenge, nil
+}
+
+// LoginMFA completes a login started by Login, exchanging the challenge
+// token and a TOTP or recovery code for an access token.
+func (u *UseCase) LoginMFA(ctx context.Context, dto *MFALoginRequest) (ret *Token, err error) {
+	span := trace.SpanFromContext(ctx)
+
+	var personId uuid.UUID
+	defer func() {
+		u.auditLogin(ctx, personId, loginMethodTOTP, "", nil, err)
+	}()
+
+	span.AddEvent("parseChallenge")
+	personId, err = u.parseToken(dto.ChallengeToken, TokenTypeMFAChallenge)
+	if err != nil {
+		span.SetStatus(codes.Error, ErrInvalidChallenge.Error())
+		return nil, errors.Join(ErrInvalidChallenge, err)
+	}
 
-	claims := jwt.MapClaims{
-		"sub": user.Id,
-		"exp": expiry.Unix(),
-		"iat": iat,
-		"nbf": nbf,
-		"iss": "http://localhost",
-		"jti": uuid.New().String(),
+	account, ip := accountKey(personId.String()), ipKey(dto.ClientIP)
+	span.AddEvent("u.guard.Check")
+	err = u.guard.Check(ctx, account, ip)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
 	}
 
-	span.AddEvent("SignedString")
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	encoded, err := token.SignedString(u.privateKey)
+	span.AddEvent("u.mfaUC.Verify")
+	err = u.mfaUC.Verify(ctx, personId, dto.Code)
 	if err != nil {
-		u.logger.Errorw("error while signing token", "error", err)
+		if errors.Is(err, mfa.ErrInvalidCode) {
+			u.guard.Fail(ctx, account, ip)
+		}
 		span.RecordError(err)
-		return nil, fmt.Errorf("create: sign token: %w", err)
+		return nil, err
 	}
 
-	expiresIn := expiry.Sub(time.Now())
-	ret := Token{
-		AccessToken: encoded,
-		ExpiresIn:   int64(expiresIn.Seconds()),
-		Sub:         user.Id,
+	u.guard.Succeed(ctx, account, ip)
+
+	ret, err = u.issueToken(ctx, personId)
+	if err != nil {
+		return nil, err
 	}
 
+	u.observeLogin(ctx, personId)
+
 	span.SetStatus(codes.Ok, "success")
-	return &ret, nil
+	return ret, nil
 }
 
-func (u *UseCase) Update(ctx context.Context, dto *UpdateInfoRequest) (*UpdateInfoResponse, error) {
+// BeginMFAWebAuthn starts a passkey assertion for the person a challenge
+// token was issued to.
+func (u *UseCase) BeginMFAWebAuthn(ctx context.Context, dto *MFAWebAuthnOptionsRequest) (*passkey.CeremonyResponse, error) {
 	span := trace.SpanFromContext(ctx)
 
+	span.AddEvent("parseChallenge")
+	personId, err := u.parseToken(dto.ChallengeToken, TokenTypeMFAChallenge)
+	if err != nil {
+		span.SetStatus(codes.Error, ErrInvalidChallenge.Error())
+		return nil, errors.Join(ErrInvalidChallenge, err)
+	}
+
+	span.AddEvent("u.passkeyUC.BeginLogin")
+	ret, err := u.passkeyUC.BeginLogin(ctx, personId)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetStatus(codes.Ok, "success")
+	return ret, nil
+}
+
+// LoginMFAWebAuthn completes a login started by Login with a passkey
+// assertion as the second factor.
+func (u *UseCase) LoginMFAWebAuthn(ctx context.Context, dto *MFAWebAuthnLoginRequest) (ret *Token, err error) {
+	span := trace.SpanFromContext(ctx)
+
+	var personId uuid.UUID
+	defer func() {
+		u.auditLogin(ctx, personId, loginMethodWebAuthn, "", nil, err)
+	}()
+
+	span.AddEvent("parseChallenge")
+	personId, err = u.parseToken(dto.ChallengeToken, TokenTypeMFAChallenge)
+	if err != nil {
+		span.SetStatus(codes.Error, ErrInvalidChallenge.Error())
+		return nil, errors.Join(ErrInvalidChallenge, err)
+	}
+
+	account, ip := accountKey(personId.String()), ipKey(dto.ClientIP)
+	span.AddEvent("u.guard.Check")
+	err = u.guard.Check(ctx, account, ip)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.AddEvent("u.passkeyUC.FinishLogin")
+	err = u.passkeyUC.FinishLogin(ctx, personId, &dto.AssertionRequest)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	ret, err = u.issueToken(ctx, personId)
+	if err != nil {
+		return nil, err
+	}
+
+	u.guard.Succeed(ctx, account, ip)
+
+	u.observeLogin(ctx, personId)
+
+	span.SetStatus(codes.Ok, "success")
+	return ret, nil
+}
+
+func (u *UseCase) BeginPasskeyLogin(ctx context.Context) (*passkey.CeremonyResponse, error) {
+	span := trace.SpanFromContext(ctx)
+
+	span.AddEvent("u.passkeyUC.BeginPasswordless")
+	ret, err := u.passkeyUC.BeginPasswordless(ctx)
+	if err != nil {
+		u.logger.Errorw("error while starting passkey login", "error", err)
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetStatus(codes.Ok, "success")
+	return ret, nil
+}
+
+// LoginPasskey logs a person in with a discoverable passkey alone. The
+// passkey requires user verification, so it stands in for both factors.
+func (u *UseCase) LoginPasskey(ctx context.Context, dto *passkey.AssertionRequest) (ret *Token, err error) {
+	span := trace.SpanFromContext(ctx)
+
+	var personId uuid.UUID
+	defer func() {
+		u.auditLogin(ctx, personId, loginMethodPasskey, "", nil, err)
+	}()
+
+	span.AddEvent("u.passkeyUC.FinishPasswordless")
+	personId, err = u.passkeyUC.FinishPasswordless(ctx, dto)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	ret, err = u.issueToken(ctx, personId)
+	if err != nil {
+		return nil, err
+	}
+
+	u.observeLogin(ctx, personId)
+
+	span.SetStatus(codes.Ok, "success")
+	return ret, nil
+}
+
+// completeLogin finishes a first factor login for personId: it issues an
+// access token, or an MFAChallenge when a second factor is configured. The
+// failed login counters of keys are cleared, and the device observed, only
+// once a token is issued, so that the first factor alone neither resets the
+// count of second factor failures nor makes the device known.
+func (u *UseCase) completeLogin(ctx context.Context, personId uuid.UUID, keys ...attemptKey) (*Token, *MFAChallenge, error) {
+	span := trace.SpanFromContext(ctx)
+
+	span.AddEvent("u.mfaUC.Enabled")
+	mfaEnabled, err := u.mfaUC.Enabled(ctx, personId)
+	if err != nil {
+		u.logger.Errorw("error while checking mfa status", "error", err)
+		span.RecordError(err)
+		return nil, nil, err
+	}
+
+	span.AddEvent("u.passkeyUC.HasCredentials")
+	hasPasskey, err := u.passkeyUC.HasCredentials(ctx, personId)
+	if err != nil {
+		u.logger.Errorw("error while checking passkeys", "error", err)
+		span.RecordError(err)
+		return nil, nil, err
+	}
+
+	var methods []string
+	if mfaEnabled {
+		methods = append(methods, mfaMethodTOTP, mfaMethodBackup)
+	}
+	if hasPasskey {
+		methods = append(methods, mfaMethodWebAuthn)
+	}
+
+	if len(methods) > 0 {
+		challenge, err := u.issueChallenge(ctx, personId, methods)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return nil, challenge, nil
+	}
+
+	ret, err := u.issueToken(ctx, personId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u.guard.Succeed(ctx, keys...)
+	u.observeLogin(ctx, personId)
+	return ret, nil, nil
+}
+
+func (u *UseCase) Update(ctx context.Context, dto *UpdateInfoRequest) (ret *UpdateInfoResponse, err error) {
+	span := trace.SpanFromContext(ctx)
+
+	defer func() {
+		u.auditAction(ctx, audit.ActionProfileUpdate, dto.Id, err)
+	}()
+
 	span.AddEvent("u.personRepository.Exists")
 	exists, err := u.personRepository.Exists(ctx, dto.Id)
 	if err != nil {
@@ -276,7 +625,7 @@ func (u *UseCase) UserInfo(id uuid.UUID, ctx context.Context) (*UserInfo, error)
 	return &ret, nil
 }
 
-func NewUseCase(personRepository, redisRepository PersonRepository, logger *zap.SugaredLogger, conf *pkg.Config, privatekey *rsa.PrivateKey, publickey *rsa.PublicKey) *UseCase {
+func NewUseCase(personRepository, redisRepository PersonRepository, logger *zap.SugaredLogger, conf *pkg.Config, privatekey *rsa.PrivateKey, publickey *rsa.PublicKey, policy RegistrationPolicy, passwords PasswordPolicy, passwordHistory PasswordHistoryRepository, inviteUC *invite.UseCase, mfaUC *mfa.UseCase, passkeyUC *passkey.UseCase, magicLinks MagicLinkRepository, guard *LoginGuard, auditUC *audit.UseCase, monitor *LoginMonitor, sessions SessionRepository, challenges challenge.Verifier) *UseCase {
 	return &UseCase{
 		personRepository: personRepository,
 		logger:           logger,
@@ -284,5 +633,17 @@ func NewUseCase(personRepository, redisRepository PersonRepository, logger *zap.
 		privateKey:       privatekey,
 		publicKey:        publickey,
 		redisRepository:  redisRepository,
+		policy:           policy,
+		passwords:        passwords,
+		passwordHistory:  passwordHistory,
+		inviteUC:         inviteUC,
+		mfaUC:            mfaUC,
+		passkeyUC:        passkeyUC,
+		magicLinks:       magicLinks,
+		guard:            guard,
+		audit:            auditUC,
+		monitor:          monitor,
+		sessions:         sessions,
+		challenges:       challenges,
 	}
 }
@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/audit"
+	"strings"
+)
+
+type psqlAuditRepository struct {
+	db *sql.DB
+}
+
+func (p psqlAuditRepository) Append(ctx context.Context, event *audit.Event) error {
+	var details []byte
+	if event.Details != nil {
+		var err error
+		details, err = json.Marshal(event.Details)
+		if err != nil {
+			return err
+		}
+	}
+
+	stmt, err := p.db.Prepare(`insert into audit_event(actor_id, subject_id, action, ip, user_agent, request_id, outcome, details)
+		values ($1, $2, $3, $4, $5, $6, $7, $8)`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+	_, err = stmt.ExecContext(
+		ctx,
+		nullUUID(event.ActorId),
+		nullUUID(event.SubjectId),
+		event.Action,
+		event.IP,
+		event.UserAgent,
+		nullUUID(event.RequestId),
+		event.Outcome,
+		details,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p psqlAuditRepository) Query(ctx context.Context, q *audit.Query) ([]audit.EventItem, error) {
+	var conditions []string
+	var args []any
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if q.ActorId != uuid.Nil {
+		where("actor_id = $%d", q.ActorId)
+	}
+	if q.SubjectId != uuid.Nil {
+		where("subject_id = $%d", q.SubjectId)
+	}
+	if q.Action != "" {
+		where("action = $%d", q.Action)
+	}
+	if q.Outcome != "" {
+		where("outcome = $%d", q.Outcome)
+	}
+	if !q.From.IsZero() {
+		where("datetime_created >= $%d", q.From)
+	}
+	if !q.To.IsZero() {
+		where("datetime_created < $%d", q.To)
+	}
+	if q.Cursor > 0 {
+		where("id < $%d", q.Cursor)
+	}
+
+	query := `select id, actor_id, subject_id, action, ip, user_agent, request_id, outcome, details, datetime_created
+		from audit_event`
+	if len(conditions) > 0 {
+		query += " where " + strings.Join(conditions, " and ")
+	}
+	args = append(args, q.Limit)
+	query += fmt.Sprintf(" order by id desc limit $%d", len(args))
+
+	stmt, err := p.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	var events []audit.EventItem
+	for rows.Next() {
+		var event audit.EventItem
+		var actorId, subjectId, requestId uuid.NullUUID
+		var details []byte
+		err = rows.Scan(
+			&event.Id,
+			&actorId,
+			&subjectId,
+			&event.Action,
+			&event.IP,
+			&event.UserAgent,
+			&requestId,
+			&event.Outcome,
+			&details,
+			&event.DatetimeCreated,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		event.ActorId = uuidPtr(actorId)
+		event.SubjectId = uuidPtr(subjectId)
+		event.RequestId = uuidPtr(requestId)
+		if details != nil {
+			err = json.Unmarshal(details, &event.Details)
+			if err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
+func uuidPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+func NewAuditRepository(db *sql.DB) audit.Repository {
+	return &psqlAuditRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/connections"
+)
+
+type psqlBlockRepository struct {
+	db *sql.DB
+}
+
+func (p psqlBlockRepository) Save(ctx context.Context, block *connections.Block) error {
+	stmt, err := p.db.Prepare(`insert into block(blocker_id, blocked_id) values ($1, $2)
+		on conflict (blocker_id, blocked_id) do update set blocker_id = excluded.blocker_id
+		returning datetime_created`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	err = stmt.QueryRowContext(ctx, block.BlockerId, block.BlockedId).Scan(&block.DatetimeCreated)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p psqlBlockRepository) Delete(ctx context.Context, blockerId, blockedId uuid.UUID) error {
+	stmt, err := p.db.Prepare(`delete from block where blocker_id = $1 and blocked_id = $2`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, blockerId, blockedId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return connections.ErrBlockNotFound
+	}
+
+	return nil
+}
+
+func (p psqlBlockRepository) Find(ctx context.Context, blockerId uuid.UUID) ([]connections.ConnectionsItem, error) {
+	stmt, err := p.db.Prepare(`select blocked_id, person.first_name, last_name, block.datetime_created FROM block
+		inner join person on person.id = block.blocked_id WHERE blocker_id = $1
+		order by block.datetime_created desc`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, blockerId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	var blocked []connections.ConnectionsItem
+	for rows.Next() {
+		var item connections.ConnectionsItem
+		err = rows.Scan(
+			&item.Id,
+			&item.FirstName,
+			&item.LastName,
+			&item.DatetimeCreated)
+		if err != nil {
+			return nil, err
+		}
+		blocked = append(blocked, item)
+	}
+
+	return blocked, rows.Err()
+}
+
+func (p psqlBlockRepository) Blocked(ctx context.Context, id, otherId uuid.UUID) (bool, error) {
+	stmt, err := p.db.Prepare(`select exists(select 1 from block
+		where (blocker_id = $1 and blocked_id = $2) or (blocker_id = $2 and blocked_id = $1))`)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+
+	var blocked bool
+	err = stmt.QueryRowContext(ctx, id, otherId).Scan(&blocked)
+	if err != nil {
+		return false, err
+	}
+
+	return blocked, nil
+}
+
+func NewBlockRepository(db *sql.DB) connections.BlockRepository {
+	return &psqlBlockRepository{
+		db: db,
+	}
+}
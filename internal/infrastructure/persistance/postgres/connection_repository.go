@@ -3,37 +3,194 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/oyamo/forumz-auth-server/internal/domain/connections"
+	"strings"
 )
 
 type psqlConnectionRepository struct {
 	db *sql.DB
 }
 
-func (p psqlConnectionRepository) Save(ctx context.Context, connection *connections.Connection) error {
-	stmt, err := p.db.Prepare(`insert into connection(user_id, connected_to)
-		values ($1, $2) on conflict (user_id, connected_to) do nothing`)
+func (p psqlConnectionRepository) Save(ctx context.Context, connection *connections.Connection) (bool, error) {
+	// Only a pending connection saved as accepted is updated, so a returned
+	// row means the connection was created or became accepted by this call.
+	stmt, err := p.db.Prepare(`insert into connection(user_id, connected_to, status)
+		values ($1, $2, $3) on conflict (user_id, connected_to) do update
+		set status = excluded.status
+		where connection.status = 'pending' and excluded.status = 'accepted'
+		returning status`)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	defer stmt.Close()
-	_, err = stmt.ExecContext(
+	err = stmt.QueryRowContext(
 		ctx,
 		connection.UserId,
 		connection.ConnectedTo,
-	)
+		connection.Status,
+	).Scan(&connection.Status)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	stmt, err = p.db.Prepare(`select status from connection where user_id = $1 and connected_to = $2`)
+	if err != nil {
+		return false, err
+	}
+
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, connection.UserId, connection.ConnectedTo).Scan(&connection.Status)
+	if err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
+
+func (p psqlConnectionRepository) Find(ctx context.Context, id uuid.UUID, q *connections.ListQuery) ([]connections.ConnectionsItem, error) {
+	return p.find(ctx, "user_id", "connected_to", id, q)
+}
+
+func (p psqlConnectionRepository) FindInbound(ctx context.Context, id uuid.UUID, q *connections.ListQuery) ([]connections.ConnectionsItem, error) {
+	return p.find(ctx, "connected_to", "user_id", id, q)
+}
+
+// find pages through the accepted connections whose owner column is id,
+// listing the people in the other column.
+func (p psqlConnectionRepository) find(ctx context.Context, owner, other string, id uuid.UUID, q *connections.ListQuery) ([]connections.ConnectionsItem, error) {
+	args := []any{id}
+	conditions := []string{fmt.Sprintf("connection.%s = $1", owner), "connection.status = 'accepted'"}
+	where := func(condition string, values ...any) {
+		positions := make([]any, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			positions[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, positions...))
+	}
+
+	if q.Prefix != "" {
+		prefix := likePrefix(q.Prefix)
+		where("(lower(person.first_name) like $%d or lower(person.last_name) like $%d)", prefix, prefix)
+	}
+
+	order := fmt.Sprintf("connection.datetime_created desc, connection.%s desc", other)
+	if q.Sort == connections.SortName {
+		order = fmt.Sprintf("lower(person.first_name), lower(person.last_name), connection.%s", other)
+	}
+
+	if c := q.Cursor; c != nil {
+		if q.Sort == connections.SortName {
+			where("(lower(person.first_name), lower(person.last_name), connection."+other+") > (lower($%d), lower($%d), $%d)",
+				c.FirstName, c.LastName, c.Id)
+		} else {
+			where("(connection.datetime_created, connection."+other+") < ($%d, $%d)", c.DatetimeCreated, c.Id)
+		}
+	}
+
+	args = append(args, q.Limit)
+	query := fmt.Sprintf(`select connection.%[1]s, person.first_name, last_name, connection.datetime_created FROM connection
+		inner join person on person.id = connection.%[1]s WHERE %[2]s
+		order by %[3]s limit $%[4]d`, other, strings.Join(conditions, " and "), order, len(args))
+
+	stmt, err := p.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	var conns []connections.ConnectionsItem
+	for rows.Next() {
+		var connection connections.ConnectionsItem
+		err = rows.Scan(
+			&connection.Id,
+			&connection.FirstName,
+			&connection.LastName,
+			&connection.DatetimeCreated)
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, connection)
+	}
+
+	return conns, rows.Err()
+}
+
+// likePrefix returns a like pattern matching lower case strings starting
+// with prefix.
+func likePrefix(prefix string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(prefix))
+	return escaped + "%"
+}
+
+func (p psqlConnectionRepository) Delete(ctx context.Context, id, connectedTo uuid.UUID) (bool, error) {
+	stmt, err := p.db.Prepare(`delete from connection where user_id = $1 and connected_to = $2 returning status`)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+
+	var status string
+	err = stmt.QueryRowContext(ctx, id, connectedTo).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return status == connections.StatusAccepted, nil
+}
+
+func (p psqlConnectionRepository) DeleteAccepted(ctx context.Context, id, connectedTo uuid.UUID) error {
+	stmt, err := p.db.Prepare(`delete from connection where user_id = $1 and connected_to = $2 and status = 'accepted'`)
 	if err != nil {
 		return err
 	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, id, connectedTo)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return connections.ErrConnectionNotFound
+	}
 
 	return nil
 }
 
-func (p psqlConnectionRepository) Find(ctx context.Context, id uuid.UUID) ([]connections.ConnectionsItem, error) {
-	stmt, err := p.db.Prepare(`select connected_to, person.first_name, last_name,  connection.datetime_created FROM connection
-                inner join person on person.id = connection.connected_to WHERE user_id = $1`)
+func (p psqlConnectionRepository) FindIncoming(ctx context.Context, id uuid.UUID) ([]connections.ConnectionsItem, error) {
+	return p.findRequests(ctx, `select user_id, person.first_name, last_name, status, connection.datetime_created FROM connection
+		inner join person on person.id = connection.user_id WHERE connected_to = $1 and status = 'pending'
+		order by connection.datetime_created desc`, id)
+}
+
+func (p psqlConnectionRepository) FindOutgoing(ctx context.Context, id uuid.UUID) ([]connections.ConnectionsItem, error) {
+	return p.findRequests(ctx, `select connected_to, person.first_name, last_name, status, connection.datetime_created FROM connection
+		inner join person on person.id = connection.connected_to WHERE user_id = $1 and status in ('pending', 'rejected')
+		order by connection.datetime_created desc`, id)
+}
+
+func (p psqlConnectionRepository) findRequests(ctx context.Context, query string, id uuid.UUID) ([]connections.ConnectionsItem, error) {
+	stmt, err := p.db.Prepare(query)
 	if err != nil {
 		return nil, err
 	}
@@ -51,6 +208,7 @@ func (p psqlConnectionRepository) Find(ctx context.Context, id uuid.UUID) ([]con
 			&connection.Id,
 			&connection.FirstName,
 			&connection.LastName,
+			&connection.Status,
 			&connection.DatetimeCreated)
 		if err != nil {
 			return nil, err
@@ -58,24 +216,155 @@ func (p psqlConnectionRepository) Find(ctx context.Context, id uuid.UUID) ([]con
 		conns = append(conns, connection)
 	}
 
-	return conns, nil
+	return conns, rows.Err()
+}
+
+func (p psqlConnectionRepository) Accept(ctx context.Context, id, connectedTo uuid.UUID) error {
+	return p.execRequest(ctx, `update connection set status = 'accepted'
+		where user_id = $1 and connected_to = $2 and status in ('pending', 'rejected')`, id, connectedTo)
 }
 
-func (p psqlConnectionRepository) Delete(ctx context.Context, id, connectedTo uuid.UUID) error {
-	stmt, err := p.db.Prepare(`delete from connection where user_id = $1 and connected_to = $2`)
+func (p psqlConnectionRepository) Reject(ctx context.Context, id, connectedTo uuid.UUID) error {
+	return p.execRequest(ctx, `update connection set status = 'rejected'
+		where user_id = $1 and connected_to = $2 and status = 'pending'`, id, connectedTo)
+}
+
+func (p psqlConnectionRepository) DeletePending(ctx context.Context, id, connectedTo uuid.UUID) error {
+	return p.execRequest(ctx, `delete from connection
+		where user_id = $1 and connected_to = $2 and status = 'pending'`, id, connectedTo)
+}
+
+// execRequest runs a statement on a single request and reports
+// ErrRequestNotFound when it matched none.
+func (p psqlConnectionRepository) execRequest(ctx context.Context, query string, id, connectedTo uuid.UUID) error {
+	stmt, err := p.db.Prepare(query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, id, connectedTo)
+	res, err := stmt.ExecContext(ctx, id, connectedTo)
 	if err != nil {
 		return err
 	}
 
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return connections.ErrRequestNotFound
+	}
+
 	return nil
 }
 
+func (p psqlConnectionRepository) FindMutual(ctx context.Context, id, otherId, after uuid.UUID, limit int) ([]connections.ConnectionsItem, error) {
+	stmt, err := p.db.Prepare(`select mine.connected_to, person.first_name, last_name, mine.datetime_created FROM connection mine
+		inner join connection theirs on theirs.connected_to = mine.connected_to
+		inner join person on person.id = mine.connected_to
+		WHERE mine.user_id = $1 and mine.status = 'accepted'
+		and theirs.user_id = $2 and theirs.status = 'accepted'
+		and not exists (select 1 from block
+			where (blocker_id in ($1, $2) and blocked_id = mine.connected_to)
+			or (blocker_id = mine.connected_to and blocked_id in ($1, $2)))
+		and mine.connected_to > $3
+		order by mine.connected_to limit $4`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, id, otherId, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	var conns []connections.ConnectionsItem
+	for rows.Next() {
+		var connection connections.ConnectionsItem
+		err = rows.Scan(
+			&connection.Id,
+			&connection.FirstName,
+			&connection.LastName,
+			&connection.DatetimeCreated)
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, connection)
+	}
+
+	return conns, rows.Err()
+}
+
+func (p psqlConnectionRepository) CountMutual(ctx context.Context, id, otherId uuid.UUID) (int, error) {
+	stmt, err := p.db.Prepare(`select count(*) FROM connection mine
+		inner join connection theirs on theirs.connected_to = mine.connected_to
+		WHERE mine.user_id = $1 and mine.status = 'accepted'
+		and theirs.user_id = $2 and theirs.status = 'accepted'
+		and not exists (select 1 from block
+			where (blocker_id in ($1, $2) and blocked_id = mine.connected_to)
+			or (blocker_id = mine.connected_to and blocked_id in ($1, $2)))`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var count int
+	err = stmt.QueryRowContext(ctx, id, otherId).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (p psqlConnectionRepository) Count(ctx context.Context, id uuid.UUID) (*connections.Counts, error) {
+	stmt, err := p.db.Prepare(`select
+		(select count(*) from connection where connected_to = $1 and status = 'accepted'),
+		(select count(*) from connection where user_id = $1 and status = 'accepted')`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var counts connections.Counts
+	err = stmt.QueryRowContext(ctx, id).Scan(&counts.Followers, &counts.Following)
+	if err != nil {
+		return nil, err
+	}
+
+	return &counts, nil
+}
+
+func (p psqlConnectionRepository) CountBatch(ctx context.Context, after uuid.UUID, limit int) ([]connections.PersonCounts, error) {
+	stmt, err := p.db.Prepare(`select person.id,
+		(select count(*) from connection where connected_to = person.id and status = 'accepted'),
+		(select count(*) from connection where user_id = person.id and status = 'accepted')
+		FROM person WHERE person.id > $1 order by person.id limit $2`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	var batch []connections.PersonCounts
+	for rows.Next() {
+		var counts connections.PersonCounts
+		err = rows.Scan(&counts.PersonId, &counts.Followers, &counts.Following)
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, counts)
+	}
+
+	return batch, rows.Err()
+}
+
 func NewConnectionRepository(db *sql.DB) connections.Repository {
 	return &psqlConnectionRepository{
 		db: db,
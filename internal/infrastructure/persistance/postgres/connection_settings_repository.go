@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/connections"
+)
+
+type psqlConnectionSettingsRepository struct {
+	db *sql.DB
+}
+
+func (p psqlConnectionSettingsRepository) Find(ctx context.Context, personId uuid.UUID) (*connections.Settings, error) {
+	stmt, err := p.db.Prepare(`select auto_accept, last_modified from connection_settings where person_id = $1`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+	settings := connections.Settings{
+		PersonId: personId,
+	}
+	err = stmt.QueryRowContext(ctx, personId).Scan(
+		&settings.AutoAccept,
+		&settings.LastModified,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (p psqlConnectionSettingsRepository) Save(ctx context.Context, settings *connections.Settings) error {
+	stmt, err := p.db.Prepare(`insert into connection_settings(person_id, auto_accept)
+		values ($1, $2) on conflict (person_id) do update
+		set auto_accept = excluded.auto_accept, last_modified = current_timestamp
+		returning last_modified`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+	err = stmt.QueryRowContext(
+		ctx,
+		settings.PersonId,
+		settings.AutoAccept,
+	).Scan(&settings.LastModified)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func NewConnectionSettingsRepository(db *sql.DB) connections.SettingsRepository {
+	return &psqlConnectionSettingsRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/passkey"
+	"strings"
+)
+
+type psqlCredentialRepository struct {
+	db *sql.DB
+}
+
+func (p psqlCredentialRepository) Save(ctx context.Context, credential *passkey.Credential) error {
+	stmt, err := p.db.Prepare(`insert into credential(id, person_id, name, public_key, attestation_type, aaguid,
+		sign_count, transports, backup_eligible, backup_state)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx,
+		credential.Id,
+		credential.PersonId,
+		credential.Name,
+		credential.PublicKey,
+		credential.AttestationType,
+		credential.AAGUID,
+		int64(credential.SignCount),
+		strings.Join(credential.Transports, ","),
+		credential.BackupEligible,
+		credential.BackupState,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p psqlCredentialRepository) Find(ctx context.Context, id []byte) (*passkey.Credential, error) {
+	stmt, err := p.db.Prepare(`select id, person_id, name, public_key, attestation_type, aaguid, sign_count,
+		transports, backup_eligible, backup_state, datetime_created, last_used from credential where id = $1`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+	return scanCredential(stmt.QueryRowContext(ctx, id))
+}
+
+func (p psqlCredentialRepository) FindByPerson(ctx context.Context, personId uuid.UUID) ([]passkey.Credential, error) {
+	stmt, err := p.db.Prepare(`select id, person_id, name, public_key, attestation_type, aaguid, sign_count,
+		transports, backup_eligible, backup_state, datetime_created, last_used from credential
+		where person_id = $1 order by datetime_created`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, personId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	var creds []passkey.Credential
+	for rows.Next() {
+		cred, err := scanCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, *cred)
+	}
+
+	return creds, rows.Err()
+}
+
+func (p psqlCredentialRepository) UpdateSignCount(ctx context.Context, id []byte, signCount uint32, backupState bool) error {
+	stmt, err := p.db.Prepare(`update credential set sign_count = $2, backup_state = $3, last_used = current_timestamp
+		where id = $1`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, id, int64(signCount), backupState)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p psqlCredentialRepository) Delete(ctx context.Context, personId uuid.UUID, id []byte) error {
+	stmt, err := p.db.Prepare(`delete from credential where person_id = $1 and id = $2`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, personId, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCredential(row scanner) (*passkey.Credential, error) {
+	var cred passkey.Credential
+	var signCount int64
+	var transports string
+	err := row.Scan(
+		&cred.Id,
+		&cred.PersonId,
+		&cred.Name,
+		&cred.PublicKey,
+		&cred.AttestationType,
+		&cred.AAGUID,
+		&signCount,
+		&transports,
+		&cred.BackupEligible,
+		&cred.BackupState,
+		&cred.DatetimeCreated,
+		&cred.LastUsed,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	cred.SignCount = uint32(signCount)
+	if transports != "" {
+		cred.Transports = strings.Split(transports, ",")
+	}
+	return &cred, nil
+}
+
+func NewCredentialRepository(db *sql.DB) passkey.Repository {
+	return &psqlCredentialRepository{
+		db: db,
+	}
+}
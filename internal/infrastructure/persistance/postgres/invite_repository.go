@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/invite"
+)
+
+type psqlInviteRepository struct {
+	db *sql.DB
+}
+
+func (p psqlInviteRepository) Save(ctx context.Context, inv *invite.Invite) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the creator serializes their concurrent saves, so the count
+	// below cannot go stale before the insert.
+	var quota, active int
+	err = tx.QueryRowContext(ctx, `select invite_quota from person where id = $1 for update`, inv.CreatedBy).Scan(&quota)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `select count(*) from invite
+		where created_by = $1 and uses < max_uses and expires_at > current_timestamp`, inv.CreatedBy).Scan(&active)
+	if err != nil {
+		return err
+	}
+	if active >= quota {
+		return invite.ErrInviteQuotaReached
+	}
+
+	_, err = tx.ExecContext(ctx, `insert into invite(code, created_by, max_uses, expires_at)
+		values ($1, $2, $3, $4)`, inv.Code, inv.CreatedBy, inv.MaxUses, inv.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p psqlInviteRepository) Find(ctx context.Context, code string) (*invite.Invite, error) {
+	stmt, err := p.db.Prepare(`select code, created_by, max_uses, uses, expires_at, datetime_created
+		from invite where code = $1`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+	var inv invite.Invite
+	err = stmt.QueryRowContext(ctx, code).Scan(
+		&inv.Code,
+		&inv.CreatedBy,
+		&inv.MaxUses,
+		&inv.Uses,
+		&inv.ExpiresAt,
+		&inv.DatetimeCreated,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inv, nil
+}
+
+func (p psqlInviteRepository) FindByCreator(ctx context.Context, createdBy uuid.UUID) ([]invite.Invite, error) {
+	stmt, err := p.db.Prepare(`select code, created_by, max_uses, uses, expires_at, datetime_created
+		from invite where created_by = $1 order by datetime_created desc`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	var invites []invite.Invite
+	for rows.Next() {
+		var inv invite.Invite
+		err = rows.Scan(
+			&inv.Code,
+			&inv.CreatedBy,
+			&inv.MaxUses,
+			&inv.Uses,
+			&inv.ExpiresAt,
+			&inv.DatetimeCreated,
+		)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, inv)
+	}
+
+	return invites, rows.Err()
+}
+
+func (p psqlInviteRepository) Claim(ctx context.Context, code string) (*invite.Invite, error) {
+	stmt, err := p.db.Prepare(`update invite set uses = uses + 1
+		where code = $1 and uses < max_uses and expires_at > current_timestamp
+		returning code, created_by, max_uses, uses, expires_at, datetime_created`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+	var inv invite.Invite
+	err = stmt.QueryRowContext(ctx, code).Scan(
+		&inv.Code,
+		&inv.CreatedBy,
+		&inv.MaxUses,
+		&inv.Uses,
+		&inv.ExpiresAt,
+		&inv.DatetimeCreated,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inv, nil
+}
+
+func (p psqlInviteRepository) Release(ctx context.Context, code string) error {
+	stmt, err := p.db.Prepare(`update invite set uses = uses - 1 where code = $1 and uses > 0`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func NewInviteRepository(db *sql.DB) invite.Repository {
+	return &psqlInviteRepository{
+		db: db,
+	}
+}
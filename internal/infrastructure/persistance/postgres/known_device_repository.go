@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/user"
+)
+
+// maxKnownDevices bounds how many devices are compared on every login.
+const maxKnownDevices = 100
+
+type psqlKnownDeviceRepository struct {
+	db *sql.DB
+}
+
+func (p psqlKnownDeviceRepository) FindByPerson(ctx context.Context, personId uuid.UUID) ([]user.KnownDevice, error) {
+	stmt, err := p.db.Prepare(`select person_id, fingerprint, user_agent, ip_prefix, country, latitude, longitude,
+		datetime_created, last_seen from known_device where person_id = $1 order by last_seen desc limit $2`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, personId, maxKnownDevices)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []user.KnownDevice
+	for rows.Next() {
+		var device user.KnownDevice
+		var latitude, longitude sql.NullFloat64
+		err = rows.Scan(&device.PersonId, &device.Fingerprint, &device.UserAgent, &device.IPPrefix, &device.Country,
+			&latitude, &longitude, &device.DatetimeCreated, &device.LastSeen)
+		if err != nil {
+			return nil, err
+		}
+
+		device.Latitude, device.Longitude = latitude.Float64, longitude.Float64
+		device.HasCoordinates = latitude.Valid && longitude.Valid
+		devices = append(devices, device)
+	}
+
+	return devices, rows.Err()
+}
+
+func (p psqlKnownDeviceRepository) Save(ctx context.Context, device *user.KnownDevice) error {
+	stmt, err := p.db.Prepare(`insert into known_device(person_id, fingerprint, user_agent, ip_prefix, country,
+		latitude, longitude) values ($1, $2, $3, $4, $5, $6, $7)
+		on conflict (person_id, fingerprint) do update set latitude = excluded.latitude,
+		longitude = excluded.longitude, last_seen = current_timestamp`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+	latitude := sql.NullFloat64{Float64: device.Latitude, Valid: device.HasCoordinates}
+	longitude := sql.NullFloat64{Float64: device.Longitude, Valid: device.HasCoordinates}
+	_, err = stmt.ExecContext(ctx, device.PersonId, device.Fingerprint, device.UserAgent, device.IPPrefix,
+		device.Country, latitude, longitude)
+	return err
+}
+
+func (p psqlKnownDeviceRepository) Delete(ctx context.Context, personId uuid.UUID, fingerprint string) error {
+	stmt, err := p.db.Prepare(`delete from known_device where person_id = $1 and fingerprint = $2`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, personId, fingerprint)
+	return err
+}
+
+func NewKnownDeviceRepository(db *sql.DB) user.KnownDeviceRepository {
+	return &psqlKnownDeviceRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/mfa"
+)
+
+type psqlMFARepository struct {
+	db *sql.DB
+}
+
+func (p psqlMFARepository) SaveFactor(ctx context.Context, factor *mfa.TOTPFactor) error {
+	stmt, err := p.db.Prepare(`insert into mfa_totp(person_id, secret) values ($1, $2)
+		on conflict (person_id) do update set secret = $2, confirmed = false, last_used_step = 0,
+		datetime_created = current_timestamp`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, factor.PersonId, factor.Secret)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p psqlMFARepository) FindFactor(ctx context.Context, personId uuid.UUID) (*mfa.TOTPFactor, error) {
+	stmt, err := p.db.Prepare(`select person_id, secret, confirmed, last_used_step, datetime_created
+		from mfa_totp where person_id = $1`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+	var factor mfa.TOTPFactor
+	err = stmt.QueryRowContext(ctx, personId).Scan(
+		&factor.PersonId,
+		&factor.Secret,
+		&factor.Confirmed,
+		&factor.LastUsedStep,
+		&factor.DatetimeCreated,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &factor, nil
+}
+
+func (p psqlMFARepository) ConfirmFactor(ctx context.Context, personId uuid.UUID) error {
+	stmt, err := p.db.Prepare(`update mfa_totp set confirmed = true where person_id = $1`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, personId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p psqlMFARepository) DeleteFactor(ctx context.Context, personId uuid.UUID) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `delete from mfa_recovery_code where person_id = $1`, personId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from mfa_totp where person_id = $1`, personId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p psqlMFARepository) UseStep(ctx context.Context, personId uuid.UUID, step int64) (bool, error) {
+	stmt, err := p.db.Prepare(`update mfa_totp set last_used_step = $2
+		where person_id = $1 and last_used_step < $2`)
+	if err != nil {
+		return false, err
+	}
+
+	defer stmt.Close()
+	res, err := stmt.ExecContext(ctx, personId, step)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (p psqlMFARepository) ReplaceRecoveryCodes(ctx context.Context, personId uuid.UUID, codeHashes []string) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `delete from mfa_recovery_code where person_id = $1`, personId)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `insert into mfa_recovery_code(person_id, code_hash) values ($1, $2)`)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+	for _, hash := range codeHashes {
+		_, err = stmt.ExecContext(ctx, personId, hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (p psqlMFARepository) UseRecoveryCode(ctx context.Context, personId uuid.UUID, codeHash string) (bool, error) {
+	stmt, err := p.db.Prepare(`update mfa_recovery_code set used_at = current_timestamp
+		where person_id = $1 and code_hash = $2 and used_at is null`)
+	if err != nil {
+		return false, err
+	}
+
+	defer stmt.Close()
+	res, err := stmt.ExecContext(ctx, personId, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func NewMFARepository(db *sql.DB) mfa.Repository {
+	return &psqlMFARepository{
+		db: db,
+	}
+}
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/user"
+)
+
+type psqlPasswordHistoryRepository struct {
+	db *sql.DB
+}
+
+func (p psqlPasswordHistoryRepository) Recent(ctx context.Context, personId uuid.UUID, n int) ([]string, error) {
+	stmt, err := p.db.Prepare(`select password_hash from password_history
+		where person_id = $1 order by id desc limit $2`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, personId, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		err = rows.Scan(&hash)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
+
+func (p psqlPasswordHistoryRepository) Add(ctx context.Context, personId uuid.UUID, passwordHash string, keep int) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `insert into password_history(person_id, password_hash) values ($1, $2)`,
+		personId, passwordHash)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from password_history where person_id = $1 and id not in (
+		select id from password_history where person_id = $1 order by id desc limit $2)`, personId, keep)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func NewPasswordHistoryRepository(db *sql.DB) user.PasswordHistoryRepository {
+	return &psqlPasswordHistoryRepository{
+		db: db,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/invite"
 	"github.com/oyamo/forumz-auth-server/internal/domain/user"
 )
 
@@ -36,6 +37,37 @@ func (repo psqlPersonRespository) Upsert(ctx context.Context, person *user.Perso
 	return nil
 }
 
+func (repo psqlPersonRespository) Create(ctx context.Context, person *user.Person, redemption *invite.Redemption) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `insert into person (id, first_name, last_name, email_address, password_hash, username, dob)
+		values ($1, $2, $3, $4, $5, $6, $7)`,
+		person.Id,
+		person.FirstName,
+		person.LastName,
+		person.EmailAddress,
+		person.PasswordHash,
+		person.Username,
+		person.Dob)
+	if err != nil {
+		return err
+	}
+
+	if redemption != nil {
+		_, err = tx.ExecContext(ctx, `insert into invite_redemption(code, invited_by, invitee) values ($1, $2, $3)`,
+			redemption.Code, redemption.InvitedBy, redemption.Invitee)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (repo psqlPersonRespository) UpdatePassword(ctx context.Context, u uuid.UUID, s string) error {
 	stmt, err := repo.db.Prepare(`update person set password_hash = $1 where id = $2`)
 	if err != nil {
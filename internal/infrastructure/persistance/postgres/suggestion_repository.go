@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/connections"
+)
+
+type psqlSuggestionRepository struct {
+	db *sql.DB
+}
+
+func (p psqlSuggestionRepository) Compute(ctx context.Context, id uuid.UUID, limit int) ([]connections.Suggestion, error) {
+	stmt, err := p.db.Prepare(`select fof.connected_to, person.first_name, last_name, count(*) as mutual FROM connection mine
+		inner join connection fof on fof.user_id = mine.connected_to and fof.status = 'accepted'
+		inner join person on person.id = fof.connected_to
+		WHERE mine.user_id = $1 and mine.status = 'accepted' and fof.connected_to <> $1
+		and not exists (select 1 from connection existing
+			where existing.user_id = $1 and existing.connected_to = fof.connected_to)
+		and not exists (select 1 from block
+			where (blocker_id = $1 and blocked_id = fof.connected_to) or (blocker_id = fof.connected_to and blocked_id = $1))
+		and not exists (select 1 from suggestion_dismissal
+			where person_id = $1 and suggested_id = fof.connected_to)
+		group by fof.connected_to, person.first_name, last_name
+		order by mutual desc, fof.connected_to limit $2`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, id, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	var suggestions []connections.Suggestion
+	for rows.Next() {
+		var suggestion connections.Suggestion
+		err = rows.Scan(
+			&suggestion.Id,
+			&suggestion.FirstName,
+			&suggestion.LastName,
+			&suggestion.MutualConnections)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, rows.Err()
+}
+
+func (p psqlSuggestionRepository) FindPersons(ctx context.Context, after uuid.UUID, limit int) ([]uuid.UUID, error) {
+	stmt, err := p.db.Prepare(`select distinct user_id FROM connection
+		WHERE user_id > $1 and status = 'accepted' order by user_id limit $2`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (p psqlSuggestionRepository) Dismiss(ctx context.Context, personId, suggestedId uuid.UUID) error {
+	stmt, err := p.db.Prepare(`insert into suggestion_dismissal(person_id, suggested_id) values ($1, $2)
+		on conflict (person_id, suggested_id) do nothing`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, personId, suggestedId)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func NewSuggestionRepository(db *sql.DB) connections.SuggestionRepository {
+	return &psqlSuggestionRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,35 @@
+package redis_cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/oyamo/forumz-auth-server/internal/domain/challenge"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+type redisChallengeRepository struct {
+	client *redis.Client
+}
+
+func (r redisChallengeRepository) Save(ctx context.Context, id string, ttl time.Duration) error {
+	_, err := r.client.Set(ctx, fmt.Sprintf("challenge-%s", id), 1, ttl).Result()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r redisChallengeRepository) Consume(ctx context.Context, id string) (bool, error) {
+	deleted, err := r.client.Del(ctx, fmt.Sprintf("challenge-%s", id)).Result()
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+func NewRedisChallengeRepository(client *redis.Client) challenge.Repository {
+	return &redisChallengeRepository{
+		client: client,
+	}
+}
@@ -0,0 +1,124 @@
+package redis_cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/connections"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+const (
+	// connectionCountsTTL lets the counters of people nobody looks at expire.
+	connectionCountsTTL = time.Hour * 24
+	reconcileLeaseKey   = "connection-counts-reconcile"
+	followersField      = "followers"
+	followingField      = "following"
+)
+
+// addCountsScript increments the counters that are kept only, so a counter
+// is never created from a partial count.
+var addCountsScript = redis.NewScript(`
+local delta = tonumber(ARGV[1])
+if redis.call('EXISTS', KEYS[1]) == 1 then
+  redis.call('HINCRBY', KEYS[1], 'following', delta)
+end
+if redis.call('EXISTS', KEYS[2]) == 1 then
+  redis.call('HINCRBY', KEYS[2], 'followers', delta)
+end
+return 0
+`)
+
+// replaceCountsScript swaps kept counters for new ones and returns the
+// previous ones, or nothing when they are not kept.
+var replaceCountsScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+  return false
+end
+local previous = redis.call('HMGET', KEYS[1], 'followers', 'following')
+redis.call('HSET', KEYS[1], 'followers', ARGV[1], 'following', ARGV[2])
+return {tonumber(previous[1]) or 0, tonumber(previous[2]) or 0}
+`)
+
+type redisConnectionCounterRepository struct {
+	client *redis.Client
+}
+
+func connectionCountsKey(id uuid.UUID) string {
+	return fmt.Sprintf("connection-counts-%s", id)
+}
+
+func (r redisConnectionCounterRepository) Get(ctx context.Context, id uuid.UUID) (*connections.Counts, error) {
+	res, err := r.client.HMGet(ctx, connectionCountsKey(id), followersField, followingField).Result()
+	if err != nil {
+		return nil, err
+	}
+	followers, ok := res[0].(string)
+	if !ok {
+		return nil, nil
+	}
+	following, ok := res[1].(string)
+	if !ok {
+		return nil, nil
+	}
+
+	var counts connections.Counts
+	counts.Followers, err = strconv.ParseInt(followers, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	counts.Following, err = strconv.ParseInt(following, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &counts, nil
+}
+
+func (r redisConnectionCounterRepository) Set(ctx context.Context, id uuid.UUID, counts *connections.Counts) error {
+	key := connectionCountsKey(id)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, followersField, counts.Followers, followingField, counts.Following)
+		pipe.Expire(ctx, key, connectionCountsTTL)
+		return nil
+	})
+	return err
+}
+
+func (r redisConnectionCounterRepository) Add(ctx context.Context, follower, followed uuid.UUID, delta int64) error {
+	return addCountsScript.Run(ctx, r.client,
+		[]string{connectionCountsKey(follower), connectionCountsKey(followed)},
+		delta,
+	).Err()
+}
+
+func (r redisConnectionCounterRepository) Replace(ctx context.Context, id uuid.UUID, counts *connections.Counts) (*connections.Counts, error) {
+	res, err := replaceCountsScript.Run(ctx, r.client,
+		[]string{connectionCountsKey(id)},
+		counts.Followers,
+		counts.Following,
+	).Int64Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &connections.Counts{
+		Followers: res[0],
+		Following: res[1],
+	}, nil
+}
+
+func (r redisConnectionCounterRepository) Lease(ctx context.Context, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, reconcileLeaseKey, uuid.NewString(), ttl).Result()
+}
+
+func NewRedisConnectionCounterRepository(client *redis.Client) connections.CounterRepository {
+	return &redisConnectionCounterRepository{
+		client: client,
+	}
+}
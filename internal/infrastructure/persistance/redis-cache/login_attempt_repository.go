@@ -0,0 +1,74 @@
+package redis_cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/oyamo/forumz-auth-server/internal/domain/user"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+type redisLoginAttemptRepository struct {
+	client *redis.Client
+}
+
+func (r redisLoginAttemptRepository) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.client.PTTL(ctx, fmt.Sprintf("login-lock-%s", key)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// Negative values mean the key does not exist or has no expiry.
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (r redisLoginAttemptRepository) Failures(ctx context.Context, key string) (int64, error) {
+	failures, err := r.client.Get(ctx, fmt.Sprintf("login-failures-%s", key)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return failures, nil
+}
+
+func (r redisLoginAttemptRepository) RecordFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	failuresKey := fmt.Sprintf("login-failures-%s", key)
+
+	pipe := r.client.TxPipeline()
+	incr := pipe.Incr(ctx, failuresKey)
+	pipe.PExpire(ctx, failuresKey, window)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return incr.Val(), nil
+}
+
+func (r redisLoginAttemptRepository) Lock(ctx context.Context, key string, d time.Duration) error {
+	_, err := r.client.Set(ctx, fmt.Sprintf("login-lock-%s", key), 1, d).Result()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r redisLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	_, err := r.client.Del(ctx, fmt.Sprintf("login-failures-%s", key), fmt.Sprintf("login-lock-%s", key)).Result()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func NewRedisLoginAttemptRepository(client *redis.Client) user.LoginAttemptRepository {
+	return &redisLoginAttemptRepository{
+		client: client,
+	}
+}
@@ -0,0 +1,35 @@
+package redis_cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/oyamo/forumz-auth-server/internal/domain/user"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+type redisMagicLinkRepository struct {
+	client *redis.Client
+}
+
+func (r redisMagicLinkRepository) Save(ctx context.Context, id string, ttl time.Duration) error {
+	_, err := r.client.Set(ctx, fmt.Sprintf("magic-link-%s", id), 1, ttl).Result()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r redisMagicLinkRepository) Consume(ctx context.Context, id string) (bool, error) {
+	deleted, err := r.client.Del(ctx, fmt.Sprintf("magic-link-%s", id)).Result()
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+func NewRedisMagicLinkRepository(client *redis.Client) user.MagicLinkRepository {
+	return &redisMagicLinkRepository{
+		client: client,
+	}
+}
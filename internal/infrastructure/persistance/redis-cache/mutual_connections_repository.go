@@ -0,0 +1,119 @@
+package redis_cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/connections"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+type redisMutualConnectionsRepository struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// mutualConnectionsGenerationKey holds a token that changes whenever the
+// connections of id change. Page keys include the tokens of both people, so
+// replacing a token drops every page involving them at once.
+func mutualConnectionsGenerationKey(id uuid.UUID) string {
+	return fmt.Sprintf("mutual-connections-generation-%s", id)
+}
+
+// mutualConnectionsKey orders the pair, so both people share the cached
+// pages.
+func (r redisMutualConnectionsRepository) mutualConnectionsKey(ctx context.Context, id, otherId, after uuid.UUID, limit int) (string, error) {
+	if otherId.String() < id.String() {
+		id, otherId = otherId, id
+	}
+
+	generations, err := r.client.MGet(ctx, mutualConnectionsGenerationKey(id), mutualConnectionsGenerationKey(otherId)).Result()
+	if err != nil {
+		return "", err
+	}
+
+	// A missing token reads as an empty one. Tokens outlive the pages keyed
+	// by them, so pages from before a token was set have expired when it
+	// does.
+	generation, otherGeneration := "", ""
+	if s, ok := generations[0].(string); ok {
+		generation = s
+	}
+	if s, ok := generations[1].(string); ok {
+		otherGeneration = s
+	}
+
+	return fmt.Sprintf("mutual-connections-%s-%s-%s-%s-%s-%d", id, generation, otherId, otherGeneration, after, limit), nil
+}
+
+func (r redisMutualConnectionsRepository) Find(ctx context.Context, id, otherId, after uuid.UUID, limit int) (*connections.MutualConnections, error) {
+	if r.ttl <= 0 {
+		return nil, nil
+	}
+
+	key, err := r.mutualConnectionsKey(ctx, id, otherId, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var page connections.MutualConnections
+	err = json.Unmarshal(res, &page)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (r redisMutualConnectionsRepository) Save(ctx context.Context, id, otherId, after uuid.UUID, limit int, page *connections.MutualConnections) error {
+	if r.ttl <= 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(page)
+	if err != nil {
+		return err
+	}
+
+	key, err := r.mutualConnectionsKey(ctx, id, otherId, after, limit)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.Set(ctx, key, b, r.ttl).Result()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r redisMutualConnectionsRepository) Invalidate(ctx context.Context, ids ...uuid.UUID) error {
+	if r.ttl <= 0 {
+		return nil
+	}
+
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			pipe.Set(ctx, mutualConnectionsGenerationKey(id), uuid.NewString(), r.ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func NewRedisMutualConnectionsRepository(client *redis.Client, ttl time.Duration) connections.MutualConnectionsCache {
+	return &redisMutualConnectionsRepository{
+		client: client,
+		ttl:    ttl,
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/invite"
 	"github.com/oyamo/forumz-auth-server/internal/domain/user"
 	"github.com/redis/go-redis/v9"
 	"time"
@@ -35,6 +36,10 @@ func (r redisPersonRepository) Upsert(ctx context.Context, person *user.Person)
 	return nil
 }
 
+func (r redisPersonRepository) Create(ctx context.Context, person *user.Person, redemption *invite.Redemption) error {
+	return errors.New("not implemented")
+}
+
 func (r redisPersonRepository) UpdatePassword(ctx context.Context, uuid uuid.UUID, s string) error {
 	return errors.New("not implemented")
 }
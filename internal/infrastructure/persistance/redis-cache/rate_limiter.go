@@ -0,0 +1,70 @@
+package redis_cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// slidingWindowScript keeps one sorted set entry per accepted request, scored
+// by its timestamp, and drops entries older than the window before counting.
+// The Redis clock is used so that replicas agree on the window.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local member = ARGV[3]
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+  redis.call('ZADD', key, now, member)
+  count = count + 1
+  allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+  reset = tonumber(oldest[2]) + window - now
+end
+
+return {allowed, limit - count, reset}
+`)
+
+type redisRateLimiter struct {
+	client *redis.Client
+}
+
+func (r redisRateLimiter) Allow(ctx context.Context, key string, limit pkg.RateLimit) (*pkg.RateLimitResult, error) {
+	res, err := slidingWindowScript.Run(ctx, r.client,
+		[]string{fmt.Sprintf("rate-limit-%s", key)},
+		limit.Window.Milliseconds(),
+		limit.Limit,
+		uuid.NewString(),
+	).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return &pkg.RateLimitResult{
+		Allowed:    res[0] == 1,
+		Limit:      limit.Limit,
+		Remaining:  int(res[1]),
+		ResetAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
+
+func NewRedisRateLimiter(client *redis.Client) pkg.RateLimiter {
+	return &redisRateLimiter{
+		client: client,
+	}
+}
@@ -0,0 +1,40 @@
+package redis_cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/user"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+type redisSessionRepository struct {
+	client *redis.Client
+}
+
+func (r redisSessionRepository) RevokeAll(ctx context.Context, personId uuid.UUID, at time.Time, ttl time.Duration) error {
+	_, err := r.client.Set(ctx, fmt.Sprintf("sessions-revoked-%s", personId), at.Unix(), ttl).Result()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r redisSessionRepository) RevokedAt(ctx context.Context, personId uuid.UUID) (time.Time, error) {
+	at, err := r.client.Get(ctx, fmt.Sprintf("sessions-revoked-%s", personId)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(at, 0), nil
+}
+
+func NewRedisSessionRepository(client *redis.Client) user.SessionRepository {
+	return &redisSessionRepository{
+		client: client,
+	}
+}
@@ -0,0 +1,72 @@
+package redis_cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/connections"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+const suggestionLeaseKey = "connection-suggestions-compute"
+
+type redisSuggestionRepository struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func suggestionsKey(id uuid.UUID) string {
+	return fmt.Sprintf("connection-suggestions-%s", id)
+}
+
+func (r redisSuggestionRepository) Find(ctx context.Context, id uuid.UUID) ([]connections.Suggestion, error) {
+	res, err := r.client.Get(ctx, suggestionsKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []connections.Suggestion{}
+	err = json.Unmarshal(res, &suggestions)
+	if err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+func (r redisSuggestionRepository) Save(ctx context.Context, id uuid.UUID, suggestions []connections.Suggestion) error {
+	if suggestions == nil {
+		suggestions = []connections.Suggestion{}
+	}
+
+	b, err := json.Marshal(suggestions)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.Set(ctx, suggestionsKey(id), b, r.ttl).Result()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r redisSuggestionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.client.Del(ctx, suggestionsKey(id)).Err()
+}
+
+func (r redisSuggestionRepository) Lease(ctx context.Context, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, suggestionLeaseKey, uuid.NewString(), ttl).Result()
+}
+
+func NewRedisSuggestionRepository(client *redis.Client, ttl time.Duration) connections.SuggestionCache {
+	return &redisSuggestionRepository{
+		client: client,
+		ttl:    ttl,
+	}
+}
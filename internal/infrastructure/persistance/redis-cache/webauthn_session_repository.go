@@ -0,0 +1,56 @@
+package redis_cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/oyamo/forumz-auth-server/internal/domain/passkey"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+type redisWebAuthnSessionRepository struct {
+	client *redis.Client
+}
+
+var (
+	webAuthnSessionTTL = time.Minute * 5
+)
+
+func (r redisWebAuthnSessionRepository) Save(ctx context.Context, key string, session *webauthn.SessionData) error {
+	b, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.Set(ctx, fmt.Sprintf("webauthn-%s", key), b, webAuthnSessionTTL).Result()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r redisWebAuthnSessionRepository) Take(ctx context.Context, key string) (*webauthn.SessionData, error) {
+	res := r.client.GetDel(ctx, fmt.Sprintf("webauthn-%s", key))
+	if res.Err() != nil {
+		if errors.Is(res.Err(), redis.Nil) {
+			return nil, passkey.ErrCeremonyNotFound
+		}
+		return nil, res.Err()
+	}
+
+	var session webauthn.SessionData
+	err := json.Unmarshal([]byte(res.Val()), &session)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func NewRedisWebAuthnSessionRepository(client *redis.Client) passkey.SessionRepository {
+	return &redisWebAuthnSessionRepository{
+		client: client,
+	}
+}
@@ -6,4 +6,6 @@ type ResponseDto struct {
 	RequestId   uuid.UUID `json:"requestId,omitempty"`
 	Description string    `json:"description"`
 	Data        any       `json:"data"`
+	// NextCursor continues paged listings, it is empty on their last page.
+	NextCursor string `json:"nextCursor,omitempty"`
 }
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/audit"
+	"github.com/oyamo/forumz-auth-server/internal/interfaces/web/dto"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type AuditHandler struct {
+	useCase *audit.UseCase
+	logger  *zap.SugaredLogger
+}
+
+// Query lists audit events for administrators and mutual TLS services,
+// filtered by the actorId, subjectId, action, outcome, from and to query
+// parameters.
+func (handler *AuditHandler) Query(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+
+	// Mutual TLS callers are identified by a service principal instead.
+	adminId := uuid.Nil
+	if _, isService := c.Get(audit.ContextKeyPrincipal); !isService {
+		initiator, exists := c.Get("initiator")
+		if !exists {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			handler.logger.Errorw("cannot find initiator from context")
+			return
+		}
+
+		var isUUID bool
+		adminId, isUUID = initiator.(uuid.UUID)
+		if !isUUID {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			handler.logger.Errorw("initiator is not uuid")
+			return
+		}
+	}
+
+	query, err := parseAuditQuery(c)
+	if err != nil {
+		responseDto.Description = err.Error()
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+	page, err := handler.useCase.Query(ctx, adminId, query)
+	if err != nil {
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+		return
+	}
+
+	responseDto.Data = page
+	responseDto.Description = "Success"
+	c.JSON(http.StatusOK, responseDto)
+}
+
+// LoginHistory lists the login attempts against the initiator's account.
+func (handler *AuditHandler) LoginHistory(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find initiator from context")
+		return
+	}
+
+	userId, isUUID := initiator.(uuid.UUID)
+	if !isUUID {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("initiator is not uuid")
+		return
+	}
+
+	query, err := parseAuditQuery(c)
+	if err != nil {
+		responseDto.Description = err.Error()
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+	page, err := handler.useCase.LoginHistory(ctx, userId, query.Cursor, query.Limit)
+	if err != nil {
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+		return
+	}
+
+	responseDto.Data = page
+	responseDto.Description = "Success"
+	c.JSON(http.StatusOK, responseDto)
+}
+
+func parseAuditQuery(c *gin.Context) (*audit.Query, error) {
+	query := &audit.Query{
+		Action:  c.Query("action"),
+		Outcome: c.Query("outcome"),
+	}
+
+	var err error
+	for param, dst := range map[string]*uuid.UUID{"actorId": &query.ActorId, "subjectId": &query.SubjectId} {
+		if val := c.Query(param); val != "" {
+			*dst, err = uuid.Parse(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s", param)
+			}
+		}
+	}
+
+	for param, dst := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if val := c.Query(param); val != "" {
+			*dst, err = time.Parse(time.RFC3339, val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s, expected an RFC 3339 timestamp", param)
+			}
+		}
+	}
+
+	if val := c.Query("cursor"); val != "" {
+		query.Cursor, err = strconv.ParseInt(val, 10, 64)
+		if err != nil || query.Cursor < 0 {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+	}
+
+	if val := c.Query("limit"); val != "" {
+		query.Limit, err = strconv.Atoi(val)
+		if err != nil || query.Limit < 0 {
+			return nil, fmt.Errorf("invalid limit")
+		}
+	}
+
+	return query, nil
+}
+
+func NewAuditHandler(useCase *audit.UseCase, logger *zap.SugaredLogger) *AuditHandler {
+	return &AuditHandler{
+		useCase: useCase,
+		logger:  logger,
+	}
+}
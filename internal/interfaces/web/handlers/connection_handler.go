@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
@@ -13,9 +14,24 @@ import (
 	"github.com/oyamo/forumz-auth-server/internal/pkg"
 	"go.uber.org/zap"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+// Notification types sent to the people involved in a connection.
+const (
+	notificationConnection          = "Connection"
+	notificationConnectionRequest   = "ConnectionRequest"
+	notificationConnectionAccepted  = "ConnectionRequestAccepted"
+	notificationConnectionRejected  = "ConnectionRequestRejected"
+	notificationConnectionCancelled = "ConnectionRequestCancelled"
+)
+
+// errSelf is returned when a person acts on themselves.
+var errSelf = errors.New("operation on self")
+
 type ConnectionHandler struct {
 	useCase       *connections.UseCase
 	personUseCase *user.UseCase
@@ -78,7 +94,7 @@ func (handler *ConnectionHandler) Connect(c *gin.Context) {
 	ctx := c.Request.Context()
 	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
 
-	connectionTo, err := handler.personUseCase.UserInfo(req.ConnectionTo, ctx)
+	_, err := handler.personUseCase.UserInfo(req.ConnectionTo, ctx)
 	if err != nil {
 		responseDto.Description = "something went wrong"
 		status := http.StatusInternalServerError
@@ -92,49 +108,130 @@ func (handler *ConnectionHandler) Connect(c *gin.Context) {
 		return
 	}
 
-	err = handler.useCase.Connect(ctx, &req)
+	connectionStatus, changed, err := handler.useCase.Connect(ctx, &req)
 	if err != nil {
-		responseDto.Description = "Something went wrong"
-		c.JSON(http.StatusInternalServerError, responseDto)
-		handler.logger.Errorw("error on connect", "error", err)
+		switch {
+		case errors.Is(err, connections.ErrRequestRejected):
+			responseDto.Description = err.Error()
+			c.JSON(http.StatusConflict, responseDto)
+		case errors.Is(err, connections.ErrBlocked):
+			// Blocked people must not learn about it.
+			responseDto.Description = "Other person not found"
+			c.JSON(http.StatusNotFound, responseDto)
+		default:
+			responseDto.Description = "Something went wrong"
+			c.JSON(http.StatusInternalServerError, responseDto)
+			handler.logger.Errorw("error on connect", "error", err)
+		}
+		return
+	}
+
+	responseDto.Data = connections.ConnectResponse{Status: connectionStatus}
+	if connectionStatus == connections.StatusPending {
+		responseDto.Description = "Connection request sent."
+		c.JSON(http.StatusAccepted, responseDto)
+		if changed {
+			handler.notify(ctx, notificationConnectionRequest, req.ConnectionTo, &req)
+		}
 		return
 	}
 
 	responseDto.Description = "Connection successfully created."
 	c.JSON(http.StatusOK, responseDto)
 
+	// Repeated requests must not repeat the events and notifications.
+	if !changed {
+		return
+	}
+
 	err = handler.jsonSender.Send("Put-Connection-v1", req)
 	if err != nil {
 		handler.logger.Error(err)
 		return
 	}
 
-	recipient, err := handler.personUseCase.UserInfo(userId, ctx)
-	if err != nil {
-		handler.logger.Error(err)
+	handler.notify(ctx, notificationConnection, req.ConnectionTo, &req)
+}
+
+func (handler *ConnectionHandler) Disconnect(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
 		return
 	}
 
-	notification := map[string]interface{}{
-		"datetimeCreated": time.Now(),
-		"recipient":       recipient.EmailAddress,
-		"type":            "Connection",
-		"additionalInfo": map[string]interface{}{
-			"connectionTo":       req.ConnectionTo,
-			"connectionFrom":     req.UserId,
-			"connectionFromName": connectionTo.FirstName,
-		},
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
 	}
 
-	err = handler.jsonSender.Send("Put-Notification-v1", notification)
+	responseDto.RequestId = requestId
+
+	var req connections.CreateConnectionDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	userId, isUUID := initiator.(uuid.UUID)
+	if !isUUID {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("initiator is not uuid")
+		return
+	}
+
+	req.UserId = userId
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	err := handler.useCase.Disconnect(ctx, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, connections.ErrConnectionNotFound):
+			responseDto.Description = err.Error()
+			c.JSON(http.StatusNotFound, responseDto)
+		default:
+			responseDto.Description = "Something went wrong"
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			handler.logger.Errorw("error on disconnect", "error", err)
+		}
+		return
+	}
+
+	responseDto.Description = "Connection successfully deleted."
+	c.JSON(http.StatusOK, responseDto)
+
+	err = handler.jsonSender.Send("Delete-Connection-v1", req)
 	if err != nil {
 		handler.logger.Error(err)
 		return
 	}
+}
 
+func (handler *ConnectionHandler) ListConnections(c *gin.Context) {
+	handler.page(c, handler.useCase.ListConnections)
 }
 
-func (handler *ConnectionHandler) Disconnect(c *gin.Context) {
+// ListFollowers lists the people connected to the authenticated person.
+func (handler *ConnectionHandler) ListFollowers(c *gin.Context) {
+	handler.page(c, handler.useCase.ListFollowers)
+}
+
+// page handles the paged listings of the authenticated person, see
+// parseListQuery.
+func (handler *ConnectionHandler) page(c *gin.Context, fn func(ctx context.Context, userId uuid.UUID, q *connections.ListQuery) ([]connections.ConnectionsItem, string, error)) {
 	var responseDto dto.ResponseDto
 	requestIdCtx, exists := c.Get("id")
 	if !exists {
@@ -151,14 +248,96 @@ func (handler *ConnectionHandler) Disconnect(c *gin.Context) {
 	}
 
 	responseDto.RequestId = requestId
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
 
-	var req connections.CreateConnectionDTO
-	if err := c.ShouldBindJSON(&req); err != nil {
-		responseDto.Description = "invalid request body"
+	userId, isUUID := initiator.(uuid.UUID)
+	if !isUUID {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("initiator is not uuid")
+		return
+	}
+
+	query, err := parseListQuery(c)
+	if err != nil {
+		responseDto.Description = err.Error()
 		c.JSON(http.StatusBadRequest, responseDto)
 		return
 	}
 
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+	conns, next, err := fn(ctx, userId, query)
+	if err != nil {
+		switch {
+		case errors.Is(err, connections.ErrInvalidSort), errors.Is(err, connections.ErrInvalidCursor):
+			responseDto.Description = err.Error()
+			c.JSON(http.StatusBadRequest, responseDto)
+		default:
+			responseDto.Description = "Something went wrong"
+			c.JSON(http.StatusInternalServerError, responseDto)
+			handler.logger.Error(err)
+		}
+		return
+	}
+
+	responseDto.Data = conns
+	responseDto.NextCursor = next
+	responseDto.Description = "Success"
+	c.JSON(http.StatusOK, responseDto)
+}
+
+// MutualConnections lists the people both the authenticated person and the
+// personId path parameter are connected to, paged by the cursor and limit
+// query parameters.
+func (handler *ConnectionHandler) MutualConnections(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	personId, err := uuid.Parse(c.Param("personId"))
+	if err != nil {
+		responseDto.Description = "invalid personId"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	var after uuid.UUID
+	if val := c.Query("cursor"); val != "" {
+		after, err = uuid.Parse(val)
+		if err != nil {
+			responseDto.Description = "invalid cursor"
+			c.JSON(http.StatusBadRequest, responseDto)
+			return
+		}
+	}
+
+	var limit int
+	if val := c.Query("limit"); val != "" {
+		limit, err = strconv.Atoi(val)
+		if err != nil || limit < 0 {
+			responseDto.Description = "invalid limit"
+			c.JSON(http.StatusBadRequest, responseDto)
+			return
+		}
+	}
+
 	initiator, exists := c.Get("initiator")
 	if !exists {
 		c.JSON(http.StatusInternalServerError, gin.H{})
@@ -173,28 +352,323 @@ func (handler *ConnectionHandler) Disconnect(c *gin.Context) {
 		return
 	}
 
-	req.UserId = userId
+	if userId == personId {
+		responseDto.Description = "Operation not allowed"
+		c.JSON(http.StatusUnprocessableEntity, responseDto)
+		return
+	}
+
 	ctx := c.Request.Context()
 	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
 
-	err := handler.useCase.Disconnect(ctx, &req)
+	_, err = handler.personUseCase.UserInfo(personId, ctx)
+	if err == nil {
+		responseDto.Data, err = handler.useCase.MutualConnections(ctx, userId, personId, after, limit)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows), errors.Is(err, connections.ErrBlocked):
+			responseDto.Description = "Other person not found"
+			c.JSON(http.StatusNotFound, responseDto)
+		default:
+			responseDto.Description = "Something went wrong"
+			c.JSON(http.StatusInternalServerError, responseDto)
+			handler.logger.Errorw("error on mutual connections", "error", err)
+		}
+		return
+	}
+
+	responseDto.Description = "Success"
+	c.JSON(http.StatusOK, responseDto)
+}
+
+func (handler *ConnectionHandler) ListIncoming(c *gin.Context) {
+	handler.list(c, handler.useCase.ListIncoming)
+}
+
+func (handler *ConnectionHandler) ListOutgoing(c *gin.Context) {
+	handler.list(c, handler.useCase.ListOutgoing)
+}
+
+// Accept accepts the request of the personId path parameter to connect to
+// the authenticated person.
+func (handler *ConnectionHandler) Accept(c *gin.Context) {
+	handler.withPerson(c, handler.useCase.Accept, func(ctx context.Context, userId, personId uuid.UUID) {
+		req := connections.CreateConnectionDTO{
+			UserId:       personId,
+			ConnectionTo: userId,
+		}
+		err := handler.jsonSender.Send("Put-Connection-v1", req)
+		if err != nil {
+			handler.logger.Error(err)
+		}
+
+		handler.notify(ctx, notificationConnectionAccepted, personId, &req)
+	})
+}
+
+// Reject rejects the request of the personId path parameter to connect to
+// the authenticated person.
+func (handler *ConnectionHandler) Reject(c *gin.Context) {
+	handler.withPerson(c, handler.useCase.Reject, func(ctx context.Context, userId, personId uuid.UUID) {
+		handler.notify(ctx, notificationConnectionRejected, personId, &connections.CreateConnectionDTO{
+			UserId:       personId,
+			ConnectionTo: userId,
+		})
+	})
+}
+
+// Cancel withdraws the request of the authenticated person to connect to the
+// personId path parameter.
+func (handler *ConnectionHandler) Cancel(c *gin.Context) {
+	handler.withPerson(c, handler.useCase.Cancel, func(ctx context.Context, userId, personId uuid.UUID) {
+		handler.notify(ctx, notificationConnectionCancelled, personId, &connections.CreateConnectionDTO{
+			UserId:       userId,
+			ConnectionTo: personId,
+		})
+	})
+}
+
+// Block blocks the personId path parameter for the authenticated person.
+func (handler *ConnectionHandler) Block(c *gin.Context) {
+	block := func(ctx context.Context, userId, personId uuid.UUID) error {
+		if userId == personId {
+			return errSelf
+		}
+
+		_, err := handler.personUseCase.UserInfo(personId, ctx)
+		if err != nil {
+			return err
+		}
+
+		return handler.useCase.Block(ctx, userId, personId)
+	}
+
+	handler.withPerson(c, block, func(ctx context.Context, userId, personId uuid.UUID) {
+		handler.sendBlock("Put-Block-v1", userId, personId)
+
+		// The block removed the connections in both directions.
+		for _, req := range []connections.CreateConnectionDTO{
+			{UserId: userId, ConnectionTo: personId},
+			{UserId: personId, ConnectionTo: userId},
+		} {
+			err := handler.jsonSender.Send("Delete-Connection-v1", req)
+			if err != nil {
+				handler.logger.Error(err)
+			}
+		}
+	})
+}
+
+func (handler *ConnectionHandler) Unblock(c *gin.Context) {
+	handler.withPerson(c, handler.useCase.Unblock, func(ctx context.Context, userId, personId uuid.UUID) {
+		handler.sendBlock("Delete-Block-v1", userId, personId)
+	})
+}
+
+func (handler *ConnectionHandler) ListBlocked(c *gin.Context) {
+	handler.list(c, handler.useCase.ListBlocked)
+}
+
+// Suggestions lists the people the authenticated person may know, those
+// sharing the most connections first, up to the limit query parameter.
+func (handler *ConnectionHandler) Suggestions(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	var limit int
+	var err error
+	if val := c.Query("limit"); val != "" {
+		limit, err = strconv.Atoi(val)
+		if err != nil || limit < 0 {
+			responseDto.Description = "invalid limit"
+			c.JSON(http.StatusBadRequest, responseDto)
+			return
+		}
+	}
+
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	userId, isUUID := initiator.(uuid.UUID)
+	if !isUUID {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("initiator is not uuid")
+		return
+	}
+
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+	suggestions, err := handler.useCase.Suggestions(ctx, userId, limit)
 	if err != nil {
 		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+		handler.logger.Errorw("error on suggestions", "error", err)
+		return
+	}
+
+	responseDto.Data = suggestions
+	responseDto.Description = "Success"
+	c.JSON(http.StatusOK, responseDto)
+}
+
+// DismissSuggestion stops suggesting the personId path parameter to the
+// authenticated person.
+func (handler *ConnectionHandler) DismissSuggestion(c *gin.Context) {
+	dismiss := func(ctx context.Context, userId, personId uuid.UUID) error {
+		if userId == personId {
+			return errSelf
+		}
+
+		_, err := handler.personUseCase.UserInfo(personId, ctx)
+		if err != nil {
+			return err
+		}
+
+		return handler.useCase.DismissSuggestion(ctx, userId, personId)
+	}
+
+	handler.withPerson(c, dismiss, func(ctx context.Context, userId, personId uuid.UUID) {})
+}
+
+// sendBlock tells the other services about a block, so that they can
+// enforce it too.
+func (handler *ConnectionHandler) sendBlock(topic string, blockerId, blockedId uuid.UUID) {
+	err := handler.jsonSender.Send(topic, connections.Block{
+		BlockerId:       blockerId,
+		BlockedId:       blockedId,
+		DatetimeCreated: time.Now(),
+	})
+	if err != nil {
+		handler.logger.Error(err)
+	}
+}
+
+func (handler *ConnectionHandler) GetSettings(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
 		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
 		return
 	}
 
-	responseDto.Description = "Connection successfully deleted."
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	userId, isUUID := initiator.(uuid.UUID)
+	if !isUUID {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("initiator is not uuid")
+		return
+	}
+
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+	settings, err := handler.useCase.Settings(ctx, userId)
+	if err != nil {
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+		handler.logger.Error(err)
+		return
+	}
+
+	responseDto.Data = settings
+	responseDto.Description = "Success"
 	c.JSON(http.StatusOK, responseDto)
+}
 
-	err = handler.jsonSender.Send("Delete-Connection-v1", req)
+func (handler *ConnectionHandler) UpdateSettings(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	var req connections.UpdateSettingsDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	if err := handler.v.Struct(req); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	userId, isUUID := initiator.(uuid.UUID)
+	if !isUUID {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("initiator is not uuid")
+		return
+	}
+
+	req.PersonId = userId
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+	settings, err := handler.useCase.UpdateSettings(ctx, &req)
 	if err != nil {
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
 		handler.logger.Error(err)
 		return
 	}
+
+	responseDto.Data = settings
+	responseDto.Description = "Success"
+	c.JSON(http.StatusOK, responseDto)
 }
 
-func (handler *ConnectionHandler) ListConnections(c *gin.Context) {
+// list handles the listings of the authenticated person.
+func (handler *ConnectionHandler) list(c *gin.Context, fn func(ctx context.Context, userId uuid.UUID) ([]connections.ConnectionsItem, error)) {
 	var responseDto dto.ResponseDto
 	requestIdCtx, exists := c.Get("id")
 	if !exists {
@@ -227,7 +701,7 @@ func (handler *ConnectionHandler) ListConnections(c *gin.Context) {
 
 	ctx := c.Request.Context()
 	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
-	conns, err := handler.useCase.ListConnections(ctx, userId)
+	items, err := fn(ctx, userId)
 	if err != nil {
 		responseDto.Description = "Something went wrong"
 		c.JSON(http.StatusInternalServerError, responseDto)
@@ -235,9 +709,145 @@ func (handler *ConnectionHandler) ListConnections(c *gin.Context) {
 		return
 	}
 
-	responseDto.Data = conns
+	responseDto.Data = items
+	responseDto.Description = "Success"
+	c.JSON(http.StatusOK, responseDto)
+}
+
+// withPerson handles the requests acting on the connection request, block or
+// suggestion between the authenticated person and the personId path
+// parameter. after runs once the response is written.
+func (handler *ConnectionHandler) withPerson(c *gin.Context, fn func(ctx context.Context, userId, personId uuid.UUID) error, after func(ctx context.Context, userId, personId uuid.UUID)) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	personId, err := uuid.Parse(c.Param("personId"))
+	if err != nil {
+		responseDto.Description = "invalid personId"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	userId, isUUID := initiator.(uuid.UUID)
+	if !isUUID {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("initiator is not uuid")
+		return
+	}
+
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+	err = fn(ctx, userId, personId)
+	if err != nil {
+		switch {
+		case errors.Is(err, connections.ErrRequestNotFound), errors.Is(err, connections.ErrBlockNotFound):
+			responseDto.Description = err.Error()
+			c.JSON(http.StatusNotFound, responseDto)
+		case errors.Is(err, sql.ErrNoRows):
+			responseDto.Description = "Other person not found"
+			c.JSON(http.StatusNotFound, responseDto)
+		case errors.Is(err, errSelf):
+			responseDto.Description = "Operation not allowed"
+			c.JSON(http.StatusUnprocessableEntity, responseDto)
+		default:
+			responseDto.Description = "Something went wrong"
+			c.JSON(http.StatusInternalServerError, responseDto)
+			handler.logger.Errorw("error on connection request", "error", err)
+		}
+		return
+	}
+
 	responseDto.Description = "Success"
 	c.JSON(http.StatusOK, responseDto)
+
+	after(ctx, userId, personId)
+}
+
+// notify sends a notification of notificationType about the connection req
+// to recipientId, one of the two people in it.
+func (handler *ConnectionHandler) notify(ctx context.Context, notificationType string, recipientId uuid.UUID, req *connections.CreateConnectionDTO) {
+	from, err := handler.personUseCase.UserInfo(req.UserId, ctx)
+	if err != nil {
+		handler.logger.Error(err)
+		return
+	}
+
+	to, err := handler.personUseCase.UserInfo(req.ConnectionTo, ctx)
+	if err != nil {
+		handler.logger.Error(err)
+		return
+	}
+
+	recipient := to
+	if recipientId == from.Id {
+		recipient = from
+	}
+
+	notification := map[string]interface{}{
+		"datetimeCreated": time.Now(),
+		"recipient":       recipient.EmailAddress,
+		"type":            notificationType,
+		"additionalInfo": map[string]interface{}{
+			"connectionTo":       req.ConnectionTo,
+			"connectionFrom":     req.UserId,
+			"connectionFromName": from.FirstName,
+			"connectionToName":   to.FirstName,
+		},
+	}
+
+	err = handler.jsonSender.Send("Put-Notification-v1", notification)
+	if err != nil {
+		handler.logger.Error(err)
+	}
+}
+
+// parseListQuery reads the sort, prefix, cursor and limit query parameters
+// of connection listings.
+func parseListQuery(c *gin.Context) (*connections.ListQuery, error) {
+	query := &connections.ListQuery{
+		Sort:   c.Query("sort"),
+		Prefix: strings.TrimSpace(c.Query("prefix")),
+	}
+	if !utf8.ValidString(query.Prefix) {
+		return nil, fmt.Errorf("invalid prefix")
+	}
+
+	var err error
+	if val := c.Query("cursor"); val != "" {
+		query.Cursor, err = connections.DecodeCursor(val)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if val := c.Query("limit"); val != "" {
+		query.Limit, err = strconv.Atoi(val)
+		if err != nil || query.Limit < 0 {
+			return nil, fmt.Errorf("invalid limit")
+		}
+	}
+
+	return query, nil
 }
 
 func NewConnectionHandler(useCase *connections.UseCase, personUseCase *user.UseCase, logger *zap.SugaredLogger, sender *pkg.JSONSender) *ConnectionHandler {
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/invite"
+	"github.com/oyamo/forumz-auth-server/internal/interfaces/web/dto"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type InviteHandler struct {
+	useCase *invite.UseCase
+	logger  *zap.SugaredLogger
+	v       *validator.Validate
+}
+
+func (handler *InviteHandler) Create(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	var req invite.CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	if err := handler.v.Struct(req); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find initiator from context")
+		return
+	}
+
+	userId, isUUID := initiator.(uuid.UUID)
+	if !isUUID {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("initiator is not uuid")
+		return
+	}
+
+	req.CreatedBy = userId
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	item, err := handler.useCase.Create(ctx, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, invite.ErrInviteQuotaReached):
+			responseDto.Description = err.Error()
+			c.JSON(http.StatusForbidden, responseDto)
+		default:
+			responseDto.Description = "Something went wrong"
+			c.JSON(http.StatusInternalServerError, responseDto)
+			handler.logger.Errorw("error on create invite", "error", err)
+		}
+		return
+	}
+
+	responseDto.Description = "Invite successfully created."
+	responseDto.Data = item
+	c.JSON(http.StatusOK, responseDto)
+}
+
+func (handler *InviteHandler) List(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find initiator from context")
+		return
+	}
+
+	userId, isUUID := initiator.(uuid.UUID)
+	if !isUUID {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("initiator is not uuid")
+		return
+	}
+
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+	invites, err := handler.useCase.List(ctx, userId)
+	if err != nil {
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+		handler.logger.Error(err)
+		return
+	}
+
+	responseDto.Data = invites
+	responseDto.Description = "Success"
+	c.JSON(http.StatusOK, responseDto)
+}
+
+func NewInviteHandler(useCase *invite.UseCase, logger *zap.SugaredLogger) *InviteHandler {
+	return &InviteHandler{
+		useCase: useCase,
+		logger:  logger,
+		v:       validator.New(),
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/mfa"
+	"github.com/oyamo/forumz-auth-server/internal/domain/user"
+	"github.com/oyamo/forumz-auth-server/internal/interfaces/web/dto"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type MFAHandler struct {
+	useCase       *mfa.UseCase
+	personUseCase *user.UseCase
+	logger        *zap.SugaredLogger
+	v             *validator.Validate
+}
+
+func (handler *MFAHandler) Enroll(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find initiator from context")
+		return
+	}
+
+	userId, isUUID := initiator.(uuid.UUID)
+	if !isUUID {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("initiator is not uuid")
+		return
+	}
+
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	info, err := handler.personUseCase.UserInfo(userId, ctx)
+	if err != nil {
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+		handler.logger.Errorw("cannot get user info", "error", err)
+		return
+	}
+
+	ret, err := handler.useCase.Enroll(ctx, userId, info.Username)
+	if err != nil {
+		handler.writeError(c, responseDto, err)
+		return
+	}
+
+	responseDto.Description = "Scan the secret with an authenticator app and confirm with a code"
+	responseDto.Data = ret
+	c.JSON(http.StatusOK, responseDto)
+}
+
+func (handler *MFAHandler) Confirm(c *gin.Context) {
+	handler.withCode(c, func(ctx context.Context, userId uuid.UUID, code string) (any, error) {
+		return handler.useCase.Confirm(ctx, userId, code)
+	})
+}
+
+func (handler *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	handler.withCode(c, func(ctx context.Context, userId uuid.UUID, code string) (any, error) {
+		return handler.personUseCase.RegenerateRecoveryCodes(ctx, userId, code)
+	})
+}
+
+func (handler *MFAHandler) Disable(c *gin.Context) {
+	handler.withCode(c, func(ctx context.Context, userId uuid.UUID, code string) (any, error) {
+		return nil, handler.personUseCase.DisableMFA(ctx, userId, code)
+	})
+}
+
+// withCode handles the requests that are authorised by a fresh TOTP or
+// recovery code from the authenticated person.
+func (handler *MFAHandler) withCode(c *gin.Context, fn func(ctx context.Context, userId uuid.UUID, code string) (any, error)) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	var req mfa.CodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	if err := handler.v.Struct(req); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find initiator from context")
+		return
+	}
+
+	userId, isUUID := initiator.(uuid.UUID)
+	if !isUUID {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("initiator is not uuid")
+		return
+	}
+
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	ret, err := fn(ctx, userId, req.Code)
+	if err != nil {
+		handler.writeError(c, responseDto, err)
+		return
+	}
+
+	responseDto.Description = "Success"
+	responseDto.Data = ret
+	c.JSON(http.StatusOK, responseDto)
+}
+
+func (handler *MFAHandler) writeError(c *gin.Context, responseDto dto.ResponseDto, err error) {
+	var lockoutErr *user.LockoutError
+	switch {
+	case errors.As(err, &lockoutErr):
+		writeLockout(c, responseDto, lockoutErr)
+	case errors.Is(err, mfa.ErrAlreadyEnrolled), errors.Is(err, mfa.ErrNotEnrolled):
+		responseDto.Description = err.Error()
+		c.JSON(http.StatusConflict, responseDto)
+	case errors.Is(err, mfa.ErrInvalidCode):
+		responseDto.Description = err.Error()
+		c.JSON(http.StatusUnauthorized, responseDto)
+	default:
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+		handler.logger.Errorw("error on mfa request", "error", err)
+	}
+}
+
+func NewMFAHandler(useCase *mfa.UseCase, personUseCase *user.UseCase, logger *zap.SugaredLogger) *MFAHandler {
+	return &MFAHandler{
+		useCase:       useCase,
+		personUseCase: personUseCase,
+		logger:        logger,
+		v:             validator.New(),
+	}
+}
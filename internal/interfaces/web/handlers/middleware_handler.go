@@ -1,15 +1,20 @@
 package handlers
 
 import (
+	"context"
 	"crypto/rsa"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/audit"
+	"github.com/oyamo/forumz-auth-server/internal/domain/user"
 	"github.com/oyamo/forumz-auth-server/internal/interfaces/web/dto"
+	"github.com/oyamo/forumz-auth-server/internal/pkg"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"go.uber.org/zap"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -19,6 +24,20 @@ import (
 type MiddlewareHandler struct {
 	publicKey *rsa.PublicKey
 	logger    *zap.SugaredLogger
+	limiter   pkg.RateLimiter
+	sessions  user.SessionRepository
+}
+
+// Keys a RateLimitPolicy can count requests by.
+const (
+	RateLimitByIP        = "ip"
+	RateLimitByInitiator = "initiator"
+)
+
+type RateLimitPolicy struct {
+	Name  string
+	KeyBy string
+	pkg.RateLimit
 }
 
 var (
@@ -46,6 +65,14 @@ var (
 		},
 		[]string{"method", "endpoint", "status"},
 	)
+
+	rateLimitChecks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_rate_limit_checks_total",
+			Help: "Rate limit decisions by policy and result",
+		},
+		[]string{"policy", "result"},
+	)
 )
 
 func (mw *MiddlewareHandler) Metrics() gin.HandlerFunc {
@@ -70,6 +97,56 @@ func (mw *MiddlewareHandler) Metrics() gin.HandlerFunc {
 	}
 }
 
+// RateLimit rejects requests with 429 once the caller exceeds the policy.
+// Callers are identified by client IP, or by the authenticated initiator
+// when the policy asks for it and AuthenticateRequest ran first. Requests
+// are let through if the limiter itself fails.
+func (mw *MiddlewareHandler) RateLimit(policy RateLimitPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if policy.Limit <= 0 {
+			c.Next()
+			return
+		}
+
+		key := "ip-" + c.ClientIP()
+		if policy.KeyBy == RateLimitByInitiator {
+			if initiator, exists := c.Get("initiator"); exists {
+				key = fmt.Sprintf("initiator-%s", initiator)
+			}
+		}
+
+		res, err := mw.limiter.Allow(c.Request.Context(), policy.Name+"-"+key, policy.RateLimit)
+		if err != nil {
+			rateLimitChecks.WithLabelValues(policy.Name, "error").Inc()
+			mw.logger.Warnw("rate limiter unavailable", zap.Error(err))
+			c.Next()
+			return
+		}
+
+		resetAfter := int(math.Ceil(res.ResetAfter.Seconds()))
+		c.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(max(res.Remaining, 0)))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(resetAfter))
+
+		if !res.Allowed {
+			rateLimitChecks.WithLabelValues(policy.Name, "limited").Inc()
+
+			var responseDto dto.ResponseDto
+			if requestId, ok := c.Value("id").(uuid.UUID); ok {
+				responseDto.RequestId = requestId
+			}
+			responseDto.Description = "Too many requests"
+			c.Header("Retry-After", strconv.Itoa(resetAfter))
+			c.JSON(http.StatusTooManyRequests, responseDto)
+			c.Abort()
+			return
+		}
+
+		rateLimitChecks.WithLabelValues(policy.Name, "allowed").Inc()
+		c.Next()
+	}
+}
+
 func (mw *MiddlewareHandler) AddRequestID(c *gin.Context) {
 	requestId, err := uuid.NewV7()
 	if err != nil {
@@ -78,9 +155,81 @@ func (mw *MiddlewareHandler) AddRequestID(c *gin.Context) {
 		return
 	}
 	c.Set("id", requestId)
+
+	// Client details for the audit log, reachable both through the gin
+	// context and the request context.
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, audit.ContextKeyClientIP, c.ClientIP())
+	ctx = context.WithValue(ctx, audit.ContextKeyUserAgent, c.Request.UserAgent())
+	c.Request = c.Request.WithContext(ctx)
+	c.Set(audit.ContextKeyClientIP, c.ClientIP())
+	c.Set(audit.ContextKeyUserAgent, c.Request.UserAgent())
+
 	c.Next()
 }
 
+// RequireAdmin only lets through initiators listed in adminIds. It must run
+// after AuthenticateRequest.
+func (mw *MiddlewareHandler) RequireAdmin(adminIds []uuid.UUID) gin.HandlerFunc {
+	admins := make(map[uuid.UUID]struct{}, len(adminIds))
+	for _, id := range adminIds {
+		admins[id] = struct{}{}
+	}
+
+	return func(c *gin.Context) {
+		var res dto.ResponseDto
+		if requestId, ok := c.Value("id").(uuid.UUID); ok {
+			res.RequestId = requestId
+		}
+
+		initiator, _ := c.Value("initiator").(uuid.UUID)
+		if _, ok := admins[initiator]; !ok {
+			res.Description = "Forbidden"
+			c.JSON(http.StatusForbidden, res)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireClientCertificate admits mutual TLS clients and maps the common name
+// of their verified certificate to a service principal. Certificates without
+// an entry in principals are refused, even when they chain to the client CA.
+func (mw *MiddlewareHandler) RequireClientCertificate(principals map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var res dto.ResponseDto
+		if requestId, ok := c.Value("id").(uuid.UUID); ok {
+			res.RequestId = requestId
+		}
+
+		state := c.Request.TLS
+		if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+			res.Description = "Client certificate required"
+			c.JSON(http.StatusUnauthorized, res)
+			c.Abort()
+			return
+		}
+
+		commonName := state.VerifiedChains[0][0].Subject.CommonName
+		principal, ok := principals[commonName]
+		if !ok {
+			mw.logger.Warnw("unknown client certificate", "commonName", commonName)
+			res.Description = "Forbidden"
+			c.JSON(http.StatusForbidden, res)
+			c.Abort()
+			return
+		}
+
+		ctx := context.WithValue(c.Request.Context(), audit.ContextKeyPrincipal, principal)
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(audit.ContextKeyPrincipal, principal)
+
+		c.Next()
+	}
+}
+
 func (mw *MiddlewareHandler) AuthenticateRequest(c *gin.Context) {
 	var res dto.ResponseDto
 	requestIdCtx, exists := c.Get("id")
@@ -127,10 +276,20 @@ func (mw *MiddlewareHandler) AuthenticateRequest(c *gin.Context) {
 		return
 	}
 
+	// Challenge tokens from a half finished login share the signing key but
+	// must never grant access.
+	if typ, ok := (*claims)["typ"].(string); ok && typ != user.TokenTypeAccess {
+		res.Description = "Invalid token"
+		c.JSON(http.StatusUnauthorized, res)
+		c.Abort()
+		return
+	}
+
 	sub, err := claims.GetSubject()
 	if err != nil {
 		res.Description = "Invalid token"
 		c.JSON(http.StatusUnauthorized, res)
+		c.Abort()
 		return
 	}
 	identity, err := uuid.Parse(sub)
@@ -141,13 +300,28 @@ func (mw *MiddlewareHandler) AuthenticateRequest(c *gin.Context) {
 		return
 	}
 
+	// Tokens issued before the person signed out everywhere are revoked.
+	// Like rate limiting this fails open, an outage of the session store must
+	// not sign everyone out.
+	revokedAt, err := mw.sessions.RevokedAt(c.Request.Context(), identity)
+	if err != nil {
+		mw.logger.Errorw("error while checking session revocation", "error", err)
+	} else if iat, err := claims.GetIssuedAt(); err == nil && iat != nil && !revokedAt.IsZero() && !iat.After(revokedAt) {
+		res.Description = "Session has been revoked"
+		c.JSON(http.StatusUnauthorized, res)
+		c.Abort()
+		return
+	}
+
 	c.Set("initiator", identity)
 	c.Next()
 }
 
-func NewMiddlewareHandler(publicKey *rsa.PublicKey, logger *zap.SugaredLogger) *MiddlewareHandler {
+func NewMiddlewareHandler(publicKey *rsa.PublicKey, logger *zap.SugaredLogger, limiter pkg.RateLimiter, sessions user.SessionRepository) *MiddlewareHandler {
 	return &MiddlewareHandler{
 		publicKey: publicKey,
 		logger:    logger,
+		limiter:   limiter,
+		sessions:  sessions,
 	}
 }
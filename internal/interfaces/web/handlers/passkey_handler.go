@@ -0,0 +1,426 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/passkey"
+	"github.com/oyamo/forumz-auth-server/internal/domain/user"
+	"github.com/oyamo/forumz-auth-server/internal/interfaces/web/dto"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+type PasskeyHandler struct {
+	useCase       *passkey.UseCase
+	personUseCase *user.UseCase
+	logger        *zap.SugaredLogger
+	v             *validator.Validate
+}
+
+func (handler *PasskeyHandler) BeginRegistration(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find initiator from context")
+		return
+	}
+
+	userId, isUUID := initiator.(uuid.UUID)
+	if !isUUID {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("initiator is not uuid")
+		return
+	}
+
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	info, err := handler.personUseCase.UserInfo(userId, ctx)
+	if err != nil {
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+		handler.logger.Errorw("cannot get user info", "error", err)
+		return
+	}
+
+	ret, err := handler.useCase.BeginRegistration(ctx, &passkey.Owner{
+		Id:          userId,
+		Username:    info.Username,
+		DisplayName: strings.TrimSpace(info.FirstName + " " + info.LastName),
+	})
+	if err != nil {
+		handler.writeError(c, responseDto, err)
+		return
+	}
+
+	responseDto.Description = "Success"
+	responseDto.Data = ret
+	c.JSON(http.StatusOK, responseDto)
+}
+
+func (handler *PasskeyHandler) FinishRegistration(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	var req passkey.FinishRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	if err := handler.v.Struct(req); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find initiator from context")
+		return
+	}
+
+	userId, isUUID := initiator.(uuid.UUID)
+	if !isUUID {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("initiator is not uuid")
+		return
+	}
+
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	ret, err := handler.useCase.FinishRegistration(ctx, userId, &req)
+	if err != nil {
+		handler.writeError(c, responseDto, err)
+		return
+	}
+
+	responseDto.Description = "Passkey successfully registered."
+	responseDto.Data = ret
+	c.JSON(http.StatusOK, responseDto)
+}
+
+func (handler *PasskeyHandler) List(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find initiator from context")
+		return
+	}
+
+	userId, isUUID := initiator.(uuid.UUID)
+	if !isUUID {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("initiator is not uuid")
+		return
+	}
+
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	ret, err := handler.useCase.List(ctx, userId)
+	if err != nil {
+		handler.writeError(c, responseDto, err)
+		return
+	}
+
+	responseDto.Description = "Success"
+	responseDto.Data = ret
+	c.JSON(http.StatusOK, responseDto)
+}
+
+func (handler *PasskeyHandler) Delete(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find initiator from context")
+		return
+	}
+
+	userId, isUUID := initiator.(uuid.UUID)
+	if !isUUID {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("initiator is not uuid")
+		return
+	}
+
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	err := handler.useCase.Delete(ctx, userId, c.Param("credentialId"))
+	if err != nil {
+		handler.writeError(c, responseDto, err)
+		return
+	}
+
+	responseDto.Description = "Passkey successfully deleted."
+	c.JSON(http.StatusOK, responseDto)
+}
+
+func (handler *PasskeyHandler) BeginLogin(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	ret, err := handler.personUseCase.BeginPasskeyLogin(ctx)
+	if err != nil {
+		handler.writeError(c, responseDto, err)
+		return
+	}
+
+	responseDto.Description = "Success"
+	responseDto.Data = ret
+	c.JSON(http.StatusOK, responseDto)
+}
+
+func (handler *PasskeyHandler) FinishLogin(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	var req passkey.AssertionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	if err := handler.v.Struct(req); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	ret, err := handler.personUseCase.LoginPasskey(ctx, &req)
+	if err != nil {
+		handler.writeError(c, responseDto, err)
+		return
+	}
+
+	responseDto.Description = "Success"
+	responseDto.Data = ret
+	c.JSON(http.StatusOK, responseDto)
+}
+
+func (handler *PasskeyHandler) BeginMFA(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	var req user.MFAWebAuthnOptionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	if err := handler.v.Struct(req); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	ret, err := handler.personUseCase.BeginMFAWebAuthn(ctx, &req)
+	if err != nil {
+		handler.writeError(c, responseDto, err)
+		return
+	}
+
+	responseDto.Description = "Success"
+	responseDto.Data = ret
+	c.JSON(http.StatusOK, responseDto)
+}
+
+func (handler *PasskeyHandler) FinishMFA(c *gin.Context) {
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		handler.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+	var req user.MFAWebAuthnLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	if err := handler.v.Struct(req); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	req.ClientIP = c.ClientIP()
+	ctx := c.Request.Context()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	ret, err := handler.personUseCase.LoginMFAWebAuthn(ctx, &req)
+	if err != nil {
+		handler.writeError(c, responseDto, err)
+		return
+	}
+
+	responseDto.Description = "Success"
+	responseDto.Data = ret
+	c.JSON(http.StatusOK, responseDto)
+}
+
+func (handler *PasskeyHandler) writeError(c *gin.Context, responseDto dto.ResponseDto, err error) {
+	var lockoutErr *user.LockoutError
+	switch {
+	case errors.As(err, &lockoutErr):
+		writeLockout(c, responseDto, lockoutErr)
+	case errors.Is(err, user.ErrInvalidChallenge),
+		errors.Is(err, passkey.ErrVerificationFailed),
+		errors.Is(err, passkey.ErrCloneDetected):
+		responseDto.Description = "passkey verification failed"
+		c.JSON(http.StatusUnauthorized, responseDto)
+		handler.logger.Warnw("passkey verification failed", "error", err)
+	case errors.Is(err, passkey.ErrCeremonyNotFound):
+		responseDto.Description = err.Error()
+		c.JSON(http.StatusBadRequest, responseDto)
+	case errors.Is(err, passkey.ErrNoCredentials):
+		responseDto.Description = err.Error()
+		c.JSON(http.StatusConflict, responseDto)
+	case errors.Is(err, passkey.ErrCredentialNotFound):
+		responseDto.Description = err.Error()
+		c.JSON(http.StatusNotFound, responseDto)
+	default:
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+		handler.logger.Errorw("error on passkey request", "error", err)
+	}
+}
+
+func NewPasskeyHandler(useCase *passkey.UseCase, personUseCase *user.UseCase, logger *zap.SugaredLogger) *PasskeyHandler {
+	return &PasskeyHandler{
+		useCase:       useCase,
+		personUseCase: personUseCase,
+		logger:        logger,
+		v:             validator.New(),
+	}
+}
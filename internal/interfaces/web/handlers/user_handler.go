@@ -7,20 +7,27 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/oyamo/forumz-auth-server/internal/domain/connections"
+	"github.com/oyamo/forumz-auth-server/internal/domain/invite"
+	"github.com/oyamo/forumz-auth-server/internal/domain/mfa"
 	"github.com/oyamo/forumz-auth-server/internal/domain/user"
 	"github.com/oyamo/forumz-auth-server/internal/interfaces/web/dto"
 	"github.com/oyamo/forumz-auth-server/internal/pkg"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type UserHandler struct {
-	useCase    *user.UseCase
-	logger     *zap.SugaredLogger
-	validator  *validator.Validate
-	jsonSender *pkg.JSONSender
-	tracer     trace.Tracer
+	useCase           *user.UseCase
+	connectionUseCase *connections.UseCase
+	logger            *zap.SugaredLogger
+	validator         *validator.Validate
+	jsonSender        *pkg.JSONSender
+	tracer            trace.Tracer
 }
 
 func (h *UserHandler) Register(c *gin.Context) {
@@ -57,9 +64,17 @@ func (h *UserHandler) Register(c *gin.Context) {
 		return
 	}
 
+	personDTO.ClientIP = c.ClientIP()
 	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
 
 	ret, err := h.useCase.Register(ctx, &personDTO)
+
+	var conflictErr *user.ConflictError
+	if h.useCase.UniformResponses() && (err == nil || errors.As(err, &conflictErr)) {
+		h.registerUniform(c, responseDto, &personDTO, ret, conflictErr)
+		return
+	}
+
 	if err == nil {
 		responseDto.Description = "Success"
 		responseDto.Data = ret
@@ -67,11 +82,29 @@ func (h *UserHandler) Register(c *gin.Context) {
 		return
 	}
 
+	var policyErr *user.PolicyError
+	var challengeErr *user.ChallengeError
 	switch {
+	case errors.As(err, &challengeErr):
+		writeChallenge(c, responseDto, challengeErr)
+		return
+	case errors.As(err, &policyErr):
+		responseDto.Description = user.ErrPolicyViolation.Error()
+		responseDto.Data = policyErr.Violations
+		c.JSON(http.StatusUnprocessableEntity, responseDto)
+		return
 	case errors.Is(err, user.ErrUserAlreadyExists):
 		responseDto.Description = err.Error()
 		c.JSON(http.StatusBadRequest, responseDto)
 		return
+	case errors.Is(err, invite.ErrInviteRequired):
+		responseDto.Description = err.Error()
+		c.JSON(http.StatusForbidden, responseDto)
+		return
+	case errors.Is(err, invite.ErrInviteInvalid):
+		responseDto.Description = err.Error()
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
 	default:
 		c.JSON(http.StatusInternalServerError, responseDto)
 	}
@@ -82,6 +115,37 @@ func (h *UserHandler) Register(c *gin.Context) {
 	}
 }
 
+// registerUniform answers a registration without revealing whether the
+// username or email address was taken. Conflicts are reported to the
+// submitted email address, which only its owner can read.
+func (h *UserHandler) registerUniform(c *gin.Context, responseDto dto.ResponseDto, request *user.RegistrationRequest, ret *user.UpdateInfoResponse, conflictErr *user.ConflictError) {
+	responseDto.Description = "Registration received, check your email to continue"
+	c.JSON(http.StatusAccepted, responseDto)
+
+	if conflictErr == nil {
+		err := h.jsonSender.Send("Put-Person-v1", ret)
+		if err != nil {
+			h.logger.Error(err)
+		}
+		return
+	}
+
+	notification := map[string]interface{}{
+		"datetimeCreated": time.Now(),
+		"recipient":       request.EmailAddress,
+		"type":            "RegistrationConflict",
+		"additionalInfo": map[string]interface{}{
+			"field":    conflictErr.Field,
+			"username": request.Username,
+		},
+	}
+
+	err := h.jsonSender.Send("Put-Notification-v1", notification)
+	if err != nil {
+		h.logger.Error(err)
+	}
+}
+
 func (h *UserHandler) Login(c *gin.Context) {
 	ctx, span := h.tracer.Start(c, "UserHandler.Login")
 	defer span.End()
@@ -116,23 +180,564 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
+	personDTO.ClientIP = c.ClientIP()
 	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
 
-	ret, err := h.useCase.Login(ctx, &personDTO)
+	ret, challenge, err := h.useCase.Login(ctx, &personDTO)
 	if err == nil {
+		if challenge != nil {
+			responseDto.Description = "Multi-factor authentication required"
+			responseDto.Data = challenge
+			c.JSON(http.StatusOK, responseDto)
+			return
+		}
+
 		responseDto.Description = "Success"
 		responseDto.Data = ret
 		c.JSON(http.StatusOK, responseDto)
 		return
 	}
 
+	var lockoutErr *user.LockoutError
+	var challengeErr *user.ChallengeError
 	switch {
+	case errors.As(err, &lockoutErr):
+		writeLockout(c, responseDto, lockoutErr)
+	case errors.As(err, &challengeErr):
+		writeChallenge(c, responseDto, challengeErr)
 	case errors.Is(err, user.ErrUserNotFound):
 		responseDto.Description = err.Error()
 		c.JSON(http.StatusBadRequest, responseDto)
 	case errors.Is(err, user.ErrIncorrectCredentials):
 		responseDto.Description = err.Error()
 		c.JSON(http.StatusUnauthorized, responseDto)
+	default:
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+	}
+}
+
+func (h *UserHandler) LoginMFA(c *gin.Context) {
+	ctx, span := h.tracer.Start(c, "UserHandler.LoginMFA")
+	defer span.End()
+
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+
+	var request user.MFALoginRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		responseDto.Description = "Invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	if err := h.validator.Struct(&request); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	request.ClientIP = c.ClientIP()
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	ret, err := h.useCase.LoginMFA(ctx, &request)
+	if err == nil {
+		responseDto.Description = "Success"
+		responseDto.Data = ret
+		c.JSON(http.StatusOK, responseDto)
+		return
+	}
+
+	var lockoutErr *user.LockoutError
+	switch {
+	case errors.As(err, &lockoutErr):
+		writeLockout(c, responseDto, lockoutErr)
+	case errors.Is(err, user.ErrInvalidChallenge), errors.Is(err, mfa.ErrNotEnrolled):
+		responseDto.Description = user.ErrInvalidChallenge.Error()
+		c.JSON(http.StatusUnauthorized, responseDto)
+	case errors.Is(err, mfa.ErrInvalidCode):
+		responseDto.Description = err.Error()
+		c.JSON(http.StatusUnauthorized, responseDto)
+	default:
+		h.logger.Errorw("error on mfa login", "error", err)
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+	}
+}
+
+// writeLockout responds with 429 and a Retry-After header in whole seconds.
+func writeLockout(c *gin.Context, responseDto dto.ResponseDto, lockoutErr *user.LockoutError) {
+	retryAfter := int(math.Ceil(lockoutErr.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	responseDto.Description = user.ErrTooManyAttempts.Error()
+	c.JSON(http.StatusTooManyRequests, responseDto)
+}
+
+// writeChallenge answers 428 with the challenge the client must solve before
+// retrying.
+func writeChallenge(c *gin.Context, responseDto dto.ResponseDto, challengeErr *user.ChallengeError) {
+	responseDto.Description = challengeErr.Error()
+	responseDto.Data = challengeErr.Challenge
+	c.JSON(http.StatusPreconditionRequired, responseDto)
+}
+
+const magicLinkNonceCookie = "magic_link_nonce"
+
+func (h *UserHandler) RequestMagicLink(c *gin.Context) {
+	ctx, span := h.tracer.Start(c, "UserHandler.RequestMagicLink")
+	defer span.End()
+
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+
+	var request user.MagicLinkRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		responseDto.Description = "Invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	if err := h.validator.Struct(&request); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	link, err := h.useCase.RequestMagicLink(ctx, &request)
+	if err != nil {
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+		return
+	}
+
+	// The response is the same whether or not the account exists.
+	maxAge := int(time.Until(link.ExpiresAt).Seconds())
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(magicLinkNonceCookie, link.Nonce, maxAge, "/api/v1/auth/magic-link", "", c.Request.TLS != nil, true)
+
+	responseDto.Description = "If the account exists, a login link has been sent to its email address"
+	c.JSON(http.StatusOK, responseDto)
+
+	if link.Recipient == "" {
+		return
+	}
+
+	notification := map[string]interface{}{
+		"datetimeCreated": time.Now(),
+		"recipient":       link.Recipient,
+		"type":            "MagicLink",
+		"additionalInfo": map[string]interface{}{
+			"link":      link.Link,
+			"expiresAt": link.ExpiresAt,
+		},
+	}
+
+	err = h.jsonSender.Send("Put-Notification-v1", notification)
+	if err != nil {
+		h.logger.Error(err)
+	}
+}
+
+func (h *UserHandler) ConsumeMagicLink(c *gin.Context) {
+	ctx, span := h.tracer.Start(c, "UserHandler.ConsumeMagicLink")
+	defer span.End()
+
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+
+	var request user.ConsumeMagicLinkRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		responseDto.Description = "Invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	if err := h.validator.Struct(&request); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	nonce, err := c.Cookie(magicLinkNonceCookie)
+	if err != nil {
+		responseDto.Description = "magic link must be opened in the browser it was requested from"
+		c.JSON(http.StatusUnauthorized, responseDto)
+		return
+	}
+
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	ret, challenge, err := h.useCase.ConsumeMagicLink(ctx, &request, nonce)
+	if err == nil {
+		c.SetCookie(magicLinkNonceCookie, "", -1, "/api/v1/auth/magic-link", "", c.Request.TLS != nil, true)
+		if challenge != nil {
+			responseDto.Description = "Multi-factor authentication required"
+			responseDto.Data = challenge
+			c.JSON(http.StatusOK, responseDto)
+			return
+		}
+
+		responseDto.Description = "Success"
+		responseDto.Data = ret
+		c.JSON(http.StatusOK, responseDto)
+		return
+	}
+
+	switch {
+	case errors.Is(err, user.ErrInvalidMagicLink):
+		responseDto.Description = user.ErrInvalidMagicLink.Error()
+		c.JSON(http.StatusUnauthorized, responseDto)
+	default:
+		h.logger.Errorw("error on magic link login", "error", err)
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+	}
+}
+
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	ctx, span := h.tracer.Start(c, "UserHandler.ChangePassword")
+	defer span.End()
+
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+
+	initiator, exists := c.Get("initiator")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("cannot find initiator from context")
+		return
+	}
+
+	var request user.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		responseDto.Description = "Invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	if err := h.validator.Struct(&request); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	request.Id = initiator.(uuid.UUID)
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	err := h.useCase.ChangePassword(ctx, &request)
+	if err == nil {
+		responseDto.Description = "Success"
+		c.JSON(http.StatusOK, responseDto)
+		return
+	}
+
+	var policyErr *user.PolicyError
+	var lockoutErr *user.LockoutError
+	switch {
+	case errors.As(err, &policyErr):
+		responseDto.Description = user.ErrPolicyViolation.Error()
+		responseDto.Data = policyErr.Violations
+		c.JSON(http.StatusUnprocessableEntity, responseDto)
+	case errors.As(err, &lockoutErr):
+		writeLockout(c, responseDto, lockoutErr)
+	case errors.Is(err, user.ErrIncorrectCredentials):
+		responseDto.Description = "current password is incorrect"
+		c.JSON(http.StatusUnauthorized, responseDto)
+	case errors.Is(err, user.ErrUserNotFound):
+		responseDto.Description = err.Error()
+		c.JSON(http.StatusNotFound, responseDto)
+	default:
+		h.logger.Errorw("error while changing password", "error", err)
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+	}
+}
+
+func (h *UserHandler) RequestPasswordReset(c *gin.Context) {
+	ctx, span := h.tracer.Start(c, "UserHandler.RequestPasswordReset")
+	defer span.End()
+
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+
+	var request user.PasswordResetRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		responseDto.Description = "Invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	if err := h.validator.Struct(&request); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	reset, err := h.useCase.RequestPasswordReset(ctx, &request)
+	if err != nil {
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+		return
+	}
+
+	// The response is the same whether or not the account exists.
+	responseDto.Description = "If the account exists, a password reset link has been sent to its email address"
+	c.JSON(http.StatusOK, responseDto)
+
+	if reset.Recipient == "" {
+		return
+	}
+
+	notification := map[string]interface{}{
+		"datetimeCreated": time.Now(),
+		"recipient":       reset.Recipient,
+		"type":            "PasswordReset",
+		"additionalInfo": map[string]interface{}{
+			"link":      reset.Link,
+			"expiresAt": reset.ExpiresAt,
+		},
+	}
+
+	err = h.jsonSender.Send("Put-Notification-v1", notification)
+	if err != nil {
+		h.logger.Error(err)
+	}
+}
+
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	ctx, span := h.tracer.Start(c, "UserHandler.ResetPassword")
+	defer span.End()
+
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+
+	var request user.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		responseDto.Description = "Invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	if err := h.validator.Struct(&request); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	_, err := h.useCase.ResetPassword(ctx, &request)
+	if err == nil {
+		responseDto.Description = "Success"
+		c.JSON(http.StatusOK, responseDto)
+		return
+	}
+
+	var policyErr *user.PolicyError
+	switch {
+	case errors.As(err, &policyErr):
+		responseDto.Description = user.ErrPolicyViolation.Error()
+		responseDto.Data = policyErr.Violations
+		c.JSON(http.StatusUnprocessableEntity, responseDto)
+	case errors.Is(err, user.ErrInvalidPasswordReset):
+		responseDto.Description = user.ErrInvalidPasswordReset.Error()
+		c.JSON(http.StatusUnauthorized, responseDto)
+	default:
+		h.logger.Errorw("error while resetting password", "error", err)
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+	}
+}
+
+// Introspect tells mutual TLS services whether an access token is active.
+func (h *UserHandler) Introspect(c *gin.Context) {
+	ctx, span := h.tracer.Start(c, "UserHandler.Introspect")
+	defer span.End()
+
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+
+	var request user.IntrospectionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		responseDto.Description = "Invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	if err := h.validator.Struct(&request); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	ret, err := h.useCase.Introspect(ctx, &request)
+	if err != nil {
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
+		return
+	}
+
+	responseDto.Description = "Success"
+	responseDto.Data = ret
+	c.JSON(http.StatusOK, responseDto)
+}
+
+// RevokeSessions handles the "this wasn't me" link of a login alert.
+func (h *UserHandler) RevokeSessions(c *gin.Context) {
+	ctx, span := h.tracer.Start(c, "UserHandler.RevokeSessions")
+	defer span.End()
+
+	var responseDto dto.ResponseDto
+	requestIdCtx, exists := c.Get("id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("cannot find id from context")
+		return
+	}
+
+	requestId, ok := requestIdCtx.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		h.logger.Errorw("requestId is not uuid type")
+		return
+	}
+
+	responseDto.RequestId = requestId
+
+	var request user.RevokeSessionsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		responseDto.Description = "Invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	if err := h.validator.Struct(&request); err != nil {
+		responseDto.Description = "invalid request body"
+		c.JSON(http.StatusBadRequest, responseDto)
+		return
+	}
+
+	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
+
+	_, err := h.useCase.RevokeSessions(ctx, &request)
+	if err == nil {
+		responseDto.Description = "All sessions have been signed out, reset your password to keep the account safe"
+		c.JSON(http.StatusOK, responseDto)
+		return
+	}
+
+	switch {
+	case errors.Is(err, user.ErrInvalidRevokeLink):
+		responseDto.Description = user.ErrInvalidRevokeLink.Error()
+		c.JSON(http.StatusUnauthorized, responseDto)
+	default:
+		h.logger.Errorw("error while revoking sessions", "error", err)
+		responseDto.Description = "Something went wrong"
+		c.JSON(http.StatusInternalServerError, responseDto)
 	}
 }
 
@@ -242,7 +847,30 @@ func (h *UserHandler) UserInfo(c *gin.Context) {
 
 	ctx = context.WithValue(ctx, "id", responseDto.RequestId)
 
+	// People blocked either way cannot see each other.
+	if viewer, ok := c.Value("initiator").(uuid.UUID); ok && viewer != personId {
+		blocked, err := h.connectionUseCase.Blocked(ctx, viewer, personId)
+		if err != nil {
+			responseDto.Description = "Something went wrong"
+			c.JSON(http.StatusInternalServerError, responseDto)
+			h.logger.Errorw("error while checking blocks", "error", err)
+			return
+		}
+		if blocked {
+			responseDto.Description = "person not found"
+			c.JSON(http.StatusNotFound, responseDto)
+			return
+		}
+	}
+
 	info, err := h.useCase.UserInfo(personId, ctx)
+	if err == nil {
+		var counts *connections.Counts
+		counts, err = h.connectionUseCase.Counts(ctx, personId)
+		if err == nil {
+			info.Followers, info.Following = counts.Followers, counts.Following
+		}
+	}
 	if err == nil {
 		responseDto.Description = "Success"
 		responseDto.Data = info
@@ -261,12 +889,13 @@ func (h *UserHandler) UserInfo(c *gin.Context) {
 
 }
 
-func NewUserHandler(useCase *user.UseCase, logger *zap.SugaredLogger, sender *pkg.JSONSender, tracer trace.Tracer) *UserHandler {
+func NewUserHandler(useCase *user.UseCase, connectionUseCase *connections.UseCase, logger *zap.SugaredLogger, sender *pkg.JSONSender, tracer trace.Tracer) *UserHandler {
 	return &UserHandler{
-		useCase:    useCase,
-		logger:     logger,
-		validator:  validator.New(),
-		jsonSender: sender,
-		tracer:     tracer,
+		useCase:           useCase,
+		connectionUseCase: connectionUseCase,
+		logger:            logger,
+		validator:         validator.New(),
+		jsonSender:        sender,
+		tracer:            tracer,
 	}
 }
@@ -3,7 +3,11 @@ package web
 import (
 	"crypto/rsa"
 	"github.com/gin-gonic/gin"
+	"github.com/oyamo/forumz-auth-server/internal/domain/audit"
 	"github.com/oyamo/forumz-auth-server/internal/domain/connections"
+	"github.com/oyamo/forumz-auth-server/internal/domain/invite"
+	"github.com/oyamo/forumz-auth-server/internal/domain/mfa"
+	"github.com/oyamo/forumz-auth-server/internal/domain/passkey"
 	"github.com/oyamo/forumz-auth-server/internal/domain/user"
 	"github.com/oyamo/forumz-auth-server/internal/interfaces/web/handlers"
 	"github.com/oyamo/forumz-auth-server/internal/pkg"
@@ -16,47 +20,161 @@ type Router struct {
 	logger       *zap.SugaredLogger
 	connectionUC *connections.UseCase
 	userUC       *user.UseCase
+	inviteUC     *invite.UseCase
+	mfaUC        *mfa.UseCase
+	passkeyUC    *passkey.UseCase
+	auditUC      *audit.UseCase
 	jsonSender   *pkg.JSONSender
 	pub          *rsa.PublicKey
 	tracer       trace.Tracer
+	conf         *pkg.Config
+	limiter      pkg.RateLimiter
+	sessions     user.SessionRepository
+}
+
+// rateLimit builds the policy for a named route from the configured limits.
+func (router *Router) rateLimit(name, keyBy string) handlers.RateLimitPolicy {
+	return handlers.RateLimitPolicy{
+		Name:      name,
+		KeyBy:     keyBy,
+		RateLimit: router.conf.RateLimits[name],
+	}
+}
+
+// engine returns a gin engine that only takes the client IP from the
+// configured proxies, since rate limits, lockouts and auditing key on it.
+func (router *Router) engine() *gin.Engine {
+	r := gin.Default()
+	err := r.SetTrustedProxies(router.conf.TrustedProxies)
+	if err != nil {
+		router.logger.Fatalw("invalid trusted proxies", "error", err)
+	}
+	return r
 }
 
 func (router *Router) Setup() *gin.Engine {
 
-	userHandler := handlers.NewUserHandler(router.userUC, router.logger, router.jsonSender, router.tracer)
+	userHandler := handlers.NewUserHandler(router.userUC, router.connectionUC, router.logger, router.jsonSender, router.tracer)
 	connectionHandler := handlers.NewConnectionHandler(router.connectionUC, router.userUC, router.logger, router.jsonSender)
-	middlewareHandler := handlers.NewMiddlewareHandler(router.pub, router.logger)
+	inviteHandler := handlers.NewInviteHandler(router.inviteUC, router.logger)
+	mfaHandler := handlers.NewMFAHandler(router.mfaUC, router.userUC, router.logger)
+	passkeyHandler := handlers.NewPasskeyHandler(router.passkeyUC, router.userUC, router.logger)
+	auditHandler := handlers.NewAuditHandler(router.auditUC, router.logger)
+	middlewareHandler := handlers.NewMiddlewareHandler(router.pub, router.logger, router.limiter, router.sessions)
 
-	r := gin.Default()
+	r := router.engine()
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	v1 := r.Group("/api/v1")
 	v1.Use(middlewareHandler.Metrics())
 	v1.Use(middlewareHandler.AddRequestID)
 
+	loginLimit := middlewareHandler.RateLimit(router.rateLimit("login", handlers.RateLimitByIP))
+	registerLimit := middlewareHandler.RateLimit(router.rateLimit("register", handlers.RateLimitByIP))
+	magicLinkLimit := middlewareHandler.RateLimit(router.rateLimit("magic-link", handlers.RateLimitByIP))
+	passwordResetLimit := middlewareHandler.RateLimit(router.rateLimit("password-reset", handlers.RateLimitByIP))
+	mfaLimit := middlewareHandler.RateLimit(router.rateLimit("mfa", handlers.RateLimitByInitiator))
+
 	auth := v1.Group("/auth")
 	conn := v1.Group("/connections")
+	invites := v1.Group("/invites")
+	blocks := v1.Group("/blocks")
+	admin := v1.Group("/admin")
 
-	auth.POST("/login", userHandler.Login)
-	auth.POST("/register", userHandler.Register)
+	auth.POST("/login", loginLimit, userHandler.Login)
+	auth.POST("/login/mfa", loginLimit, userHandler.LoginMFA)
+	auth.POST("/login/mfa/webauthn/options", loginLimit, passkeyHandler.BeginMFA)
+	auth.POST("/login/mfa/webauthn", loginLimit, passkeyHandler.FinishMFA)
+	auth.POST("/passkeys/login/options", loginLimit, passkeyHandler.BeginLogin)
+	auth.POST("/passkeys/login", loginLimit, passkeyHandler.FinishLogin)
+	auth.POST("/magic-link", magicLinkLimit, userHandler.RequestMagicLink)
+	auth.POST("/magic-link/consume", loginLimit, userHandler.ConsumeMagicLink)
+	auth.POST("/register", registerLimit, userHandler.Register)
+	auth.POST("/password", middlewareHandler.AuthenticateRequest, userHandler.ChangePassword)
+	auth.POST("/password/reset", passwordResetLimit, userHandler.RequestPasswordReset)
+	auth.POST("/password/reset/confirm", passwordResetLimit, userHandler.ResetPassword)
+	auth.POST("/sessions/revoke", passwordResetLimit, userHandler.RevokeSessions)
 	auth.GET("/persons/:personId", middlewareHandler.AuthenticateRequest, userHandler.UserInfo)
 	auth.PATCH("/persons/:personId", middlewareHandler.AuthenticateRequest, userHandler.Update)
+	auth.POST("/mfa/totp", middlewareHandler.AuthenticateRequest, mfaLimit, mfaHandler.Enroll)
+	auth.POST("/mfa/totp/confirm", middlewareHandler.AuthenticateRequest, mfaLimit, mfaHandler.Confirm)
+	auth.DELETE("/mfa/totp", middlewareHandler.AuthenticateRequest, mfaLimit, mfaHandler.Disable)
+	auth.POST("/mfa/recovery-codes", middlewareHandler.AuthenticateRequest, mfaLimit, mfaHandler.RegenerateRecoveryCodes)
+	auth.POST("/passkeys/registration/options", middlewareHandler.AuthenticateRequest, passkeyHandler.BeginRegistration)
+	auth.POST("/passkeys/registration", middlewareHandler.AuthenticateRequest, passkeyHandler.FinishRegistration)
+	auth.GET("/passkeys", middlewareHandler.AuthenticateRequest, passkeyHandler.List)
+	auth.DELETE("/passkeys/:credentialId", middlewareHandler.AuthenticateRequest, passkeyHandler.Delete)
+	auth.GET("/login-history", middlewareHandler.AuthenticateRequest, auditHandler.LoginHistory)
 
 	conn.Use(middlewareHandler.AuthenticateRequest)
+	conn.Use(middlewareHandler.RateLimit(router.rateLimit("connections", handlers.RateLimitByInitiator)))
 	conn.POST("/", connectionHandler.Connect)
 	conn.GET("/", connectionHandler.ListConnections)
 	conn.DELETE("/", connectionHandler.Disconnect)
+	conn.GET("/followers", connectionHandler.ListFollowers)
+	conn.GET("/mutual/:personId", connectionHandler.MutualConnections)
+	conn.GET("/suggestions", connectionHandler.Suggestions)
+	conn.DELETE("/suggestions/:personId", connectionHandler.DismissSuggestion)
+	conn.GET("/requests/incoming", connectionHandler.ListIncoming)
+	conn.GET("/requests/outgoing", connectionHandler.ListOutgoing)
+	conn.POST("/requests/:personId/accept", connectionHandler.Accept)
+	conn.POST("/requests/:personId/reject", connectionHandler.Reject)
+	conn.DELETE("/requests/:personId", connectionHandler.Cancel)
+	conn.GET("/settings", connectionHandler.GetSettings)
+	conn.PUT("/settings", connectionHandler.UpdateSettings)
+
+	blocks.Use(middlewareHandler.AuthenticateRequest)
+	blocks.Use(middlewareHandler.RateLimit(router.rateLimit("connections", handlers.RateLimitByInitiator)))
+	blocks.GET("/", connectionHandler.ListBlocked)
+	blocks.POST("/:personId", connectionHandler.Block)
+	blocks.DELETE("/:personId", connectionHandler.Unblock)
+
+	invites.Use(middlewareHandler.AuthenticateRequest)
+	invites.Use(middlewareHandler.RateLimit(router.rateLimit("invites", handlers.RateLimitByInitiator)))
+	invites.POST("/", inviteHandler.Create)
+	invites.GET("/", inviteHandler.List)
+
+	admin.Use(middlewareHandler.AuthenticateRequest)
+	admin.Use(middlewareHandler.RequireAdmin(router.conf.AdminIds))
+	admin.GET("/audit-events", auditHandler.Query)
+
+	return r
+}
+
+// SetupInternal builds the engine served on the mutual TLS listener. Every
+// route requires a client certificate signed by the configured CA.
+func (router *Router) SetupInternal() *gin.Engine {
+	userHandler := handlers.NewUserHandler(router.userUC, router.connectionUC, router.logger, router.jsonSender, router.tracer)
+	auditHandler := handlers.NewAuditHandler(router.auditUC, router.logger)
+	middlewareHandler := handlers.NewMiddlewareHandler(router.pub, router.logger, router.limiter, router.sessions)
+
+	r := router.engine()
+
+	internal := r.Group("/internal/v1")
+	internal.Use(middlewareHandler.Metrics())
+	internal.Use(middlewareHandler.AddRequestID)
+	internal.Use(middlewareHandler.RequireClientCertificate(router.conf.MTLSPrincipals))
+
+	internal.POST("/introspect", userHandler.Introspect)
+	internal.GET("/admin/audit-events", auditHandler.Query)
 
 	return r
 }
 
-func NewRouter(logger *zap.SugaredLogger, sender *pkg.JSONSender, connectionUC *connections.UseCase, userUC *user.UseCase, pub *rsa.PublicKey, tracer trace.Tracer) *Router {
+func NewRouter(logger *zap.SugaredLogger, sender *pkg.JSONSender, connectionUC *connections.UseCase, userUC *user.UseCase, inviteUC *invite.UseCase, mfaUC *mfa.UseCase, passkeyUC *passkey.UseCase, auditUC *audit.UseCase, pub *rsa.PublicKey, tracer trace.Tracer, conf *pkg.Config, limiter pkg.RateLimiter, sessions user.SessionRepository) *Router {
 	return &Router{
 		logger:       logger,
 		connectionUC: connectionUC,
 		userUC:       userUC,
+		inviteUC:     inviteUC,
+		mfaUC:        mfaUC,
+		passkeyUC:    passkeyUC,
+		auditUC:      auditUC,
 		jsonSender:   sender,
 		pub:          pub,
 		tracer:       tracer,
+		conf:         conf,
+		limiter:      limiter,
+		sessions:     sessions,
 	}
 }
@@ -1,8 +1,15 @@
 package pkg
 
 import (
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
+	"math"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -13,6 +20,90 @@ type Config struct {
 	KafkaConsumerServer string
 	KafkaProducerServer string
 	RedisServer         string
+
+	MinimumAge            int
+	ReservedUsernames     []string
+	UsernameMinLength     int
+	UsernameMaxLength     int
+	UsernameCharset       string
+	BlockedEmailDomains   []string
+	BlockDisposableEmails bool
+
+	InviteOnly        bool
+	InviteAutoConnect bool
+
+	// ConnectionRequests makes connections wait for the approval of the
+	// person connected to, unless they auto-accept.
+	ConnectionRequests bool
+	// MutualConnectionsTTL is how long pages of mutual connections are
+	// cached.
+	MutualConnectionsTTL time.Duration
+	// CounterReconcileInterval is how often cached connection counters are
+	// checked against the database.
+	CounterReconcileInterval time.Duration
+	// SuggestionsInterval is how often connection suggestions are computed,
+	// and SuggestionsTTL how long they are cached.
+	SuggestionsInterval time.Duration
+	SuggestionsTTL      time.Duration
+
+	MFAIssuer string
+
+	WebAuthnRPID          string
+	WebAuthnRPDisplayName string
+	WebAuthnRPOrigins     []string
+
+	MagicLinkURL string
+	MagicLinkTTL time.Duration
+
+	LoginMaxAccountFailures int
+	LoginMaxIPFailures      int
+	LoginFailureWindow      time.Duration
+	LoginLockoutBase        time.Duration
+	LoginLockoutMax         time.Duration
+
+	RateLimits map[string]RateLimit
+
+	PasswordMinLength  int
+	PasswordMaxLength  int
+	PasswordMinScore   int
+	PasswordBreachFile string
+	PasswordResetURL   string
+	PasswordResetTTL   time.Duration
+	PasswordHistory    int
+
+	PasswordHashParams Params
+	PasswordPeppers    map[int][]byte
+
+	UniformAuthResponses bool
+
+	AdminIds []uuid.UUID
+
+	LoginAlerts         bool
+	GeoIPFile           string
+	LoginAlertURL       string
+	LoginAlertTTL       time.Duration
+	LoginMaxTravelSpeed int
+
+	ChallengeProvider           string
+	ChallengeSiteKey            string
+	ChallengeSecret             string
+	ChallengeDifficulty         int
+	ChallengeAfterFailures      int
+	ChallengeAfterRegistrations int
+	ChallengeWindow             time.Duration
+	ChallengeNewNetworks        bool
+
+	HTTPAddr          string
+	TLSAddr           string
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSReloadInterval time.Duration
+	MTLSAddr          string
+	MTLSClientCAFile  string
+	MTLSPrincipals    map[string]string
+	// TrustedProxies are the addresses and CIDR ranges allowed to report the
+	// client IP in X-Forwarded-For. Without them the peer address is used.
+	TrustedProxies []string
 }
 
 const (
@@ -23,48 +114,546 @@ const (
 	envKafkaConsumer  = "AUTH_SERVICE_KAFKA_CONSUMER"
 	envKafkaProducer  = "AUTH_SERVICE_KAFKA_PRODUCER"
 	envRedisServer    = "AUTH_SERVICE_REDIS_SERVER"
+
+	envMinimumAge            = "AUTH_SERVICE_MINIMUM_AGE"
+	envReservedUsernames     = "AUTH_SERVICE_RESERVED_USERNAMES"
+	envUsernameMinLength     = "AUTH_SERVICE_USERNAME_MIN_LENGTH"
+	envUsernameMaxLength     = "AUTH_SERVICE_USERNAME_MAX_LENGTH"
+	envUsernameCharset       = "AUTH_SERVICE_USERNAME_CHARSET"
+	envBlockedEmailDomains   = "AUTH_SERVICE_BLOCKED_EMAIL_DOMAINS"
+	envBlockDisposableEmails = "AUTH_SERVICE_BLOCK_DISPOSABLE_EMAILS"
+	envInviteOnly            = "AUTH_SERVICE_INVITE_ONLY"
+	envInviteAutoConnect     = "AUTH_SERVICE_INVITE_AUTO_CONNECT"
+	envConnectionRequests    = "AUTH_SERVICE_CONNECTION_REQUESTS"
+	envMutualConnectionsTTL  = "AUTH_SERVICE_MUTUAL_CONNECTIONS_TTL"
+	envCounterReconcile      = "AUTH_SERVICE_COUNTER_RECONCILE_INTERVAL"
+	envSuggestionsInterval   = "AUTH_SERVICE_SUGGESTIONS_INTERVAL"
+	envSuggestionsTTL        = "AUTH_SERVICE_SUGGESTIONS_TTL"
+	envMFAIssuer             = "AUTH_SERVICE_MFA_ISSUER"
+	envWebAuthnRPID          = "AUTH_SERVICE_WEBAUTHN_RP_ID"
+	envWebAuthnRPDisplayName = "AUTH_SERVICE_WEBAUTHN_RP_DISPLAY_NAME"
+	envWebAuthnRPOrigins     = "AUTH_SERVICE_WEBAUTHN_RP_ORIGINS"
+	envMagicLinkURL          = "AUTH_SERVICE_MAGIC_LINK_URL"
+	envMagicLinkTTL          = "AUTH_SERVICE_MAGIC_LINK_TTL"
+	envLoginMaxAccountFails  = "AUTH_SERVICE_LOGIN_MAX_ACCOUNT_FAILURES"
+	envLoginMaxIPFails       = "AUTH_SERVICE_LOGIN_MAX_IP_FAILURES"
+	envLoginFailureWindow    = "AUTH_SERVICE_LOGIN_FAILURE_WINDOW"
+	envLoginLockoutBase      = "AUTH_SERVICE_LOGIN_LOCKOUT_BASE"
+	envLoginLockoutMax       = "AUTH_SERVICE_LOGIN_LOCKOUT_MAX"
+	envRateLimits            = "AUTH_SERVICE_RATE_LIMITS"
+	envPasswordMinLength     = "AUTH_SERVICE_PASSWORD_MIN_LENGTH"
+	envPasswordMaxLength     = "AUTH_SERVICE_PASSWORD_MAX_LENGTH"
+	envPasswordMinScore      = "AUTH_SERVICE_PASSWORD_MIN_SCORE"
+	envPasswordBreachFile    = "AUTH_SERVICE_PASSWORD_BREACH_FILE"
+	envPasswordResetURL      = "AUTH_SERVICE_PASSWORD_RESET_URL"
+	envPasswordResetTTL      = "AUTH_SERVICE_PASSWORD_RESET_TTL"
+	envPasswordHistory       = "AUTH_SERVICE_PASSWORD_HISTORY"
+	envArgon2Memory          = "AUTH_SERVICE_ARGON2_MEMORY"
+	envArgon2Iterations      = "AUTH_SERVICE_ARGON2_ITERATIONS"
+	envArgon2Parallelism     = "AUTH_SERVICE_ARGON2_PARALLELISM"
+	envPasswordPeppers       = "AUTH_SERVICE_PASSWORD_PEPPERS"
+	envPasswordPepperFile    = "AUTH_SERVICE_PASSWORD_PEPPER_FILE"
+	envPasswordPepperVersion = "AUTH_SERVICE_PASSWORD_PEPPER_VERSION"
+	envUniformAuthResponses  = "AUTH_SERVICE_UNIFORM_AUTH_RESPONSES"
+	envAdminIds              = "AUTH_SERVICE_ADMIN_IDS"
+	envLoginAlerts           = "AUTH_SERVICE_LOGIN_ALERTS"
+	envGeoIPFile             = "AUTH_SERVICE_GEOIP_FILE"
+	envLoginAlertURL         = "AUTH_SERVICE_LOGIN_ALERT_URL"
+	envLoginAlertTTL         = "AUTH_SERVICE_LOGIN_ALERT_TTL"
+	envLoginMaxTravelSpeed   = "AUTH_SERVICE_LOGIN_MAX_TRAVEL_SPEED"
+	envChallengeProvider     = "AUTH_SERVICE_CHALLENGE_PROVIDER"
+	envChallengeSiteKey      = "AUTH_SERVICE_CHALLENGE_SITE_KEY"
+	envChallengeSecret       = "AUTH_SERVICE_CHALLENGE_SECRET"
+	envChallengeDifficulty   = "AUTH_SERVICE_CHALLENGE_DIFFICULTY"
+	envChallengeAfterFails   = "AUTH_SERVICE_CHALLENGE_AFTER_FAILURES"
+	envChallengeAfterRegs    = "AUTH_SERVICE_CHALLENGE_AFTER_REGISTRATIONS"
+	envChallengeWindow       = "AUTH_SERVICE_CHALLENGE_WINDOW"
+	envChallengeNewNetworks  = "AUTH_SERVICE_CHALLENGE_NEW_NETWORKS"
+	envHTTPAddr              = "AUTH_SERVICE_HTTP_ADDR"
+	envTLSAddr               = "AUTH_SERVICE_TLS_ADDR"
+	envTLSCertFile           = "AUTH_SERVICE_TLS_CERT_FILE"
+	envTLSKeyFile            = "AUTH_SERVICE_TLS_KEY_FILE"
+	envTLSReloadInterval     = "AUTH_SERVICE_TLS_RELOAD_INTERVAL"
+	envMTLSAddr              = "AUTH_SERVICE_MTLS_ADDR"
+	envMTLSClientCAFile      = "AUTH_SERVICE_MTLS_CLIENT_CA_FILE"
+	envMTLSPrincipals        = "AUTH_SERVICE_MTLS_PRINCIPALS"
+	envTrustedProxies        = "AUTH_SERVICE_TRUSTED_PROXIES"
+	envSecretsDir            = "AUTH_SERVICE_SECRETS_DIR"
+	envVaultAddr             = "AUTH_SERVICE_VAULT_ADDR"
+	envVaultToken            = "AUTH_SERVICE_VAULT_TOKEN"
+	envVaultMount            = "AUTH_SERVICE_VAULT_MOUNT"
+	envVaultNamespace        = "AUTH_SERVICE_VAULT_NAMESPACE"
 )
 
+// envPrefix is shared by every variable NewConfig reads.
+const envPrefix = "AUTH_SERVICE_"
+
+// maxUsernameLength is the longest username the person table stores.
+const maxUsernameLength = 32
+
+const (
+	defaultMinimumAge        = 13
+	defaultUsernameMinLength = 3
+	defaultUsernameMaxLength = maxUsernameLength
+	defaultUsernameCharset   = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_."
+	defaultMFAIssuer         = "Forumz"
+	defaultWebAuthnRPID      = "localhost"
+	defaultMagicLinkURL      = "http://localhost:3000/magic-link"
+	defaultMagicLinkTTL      = time.Minute * 10
+	defaultLoginMaxAccount   = 5
+	defaultLoginMaxIP        = 20
+	defaultLoginWindow       = time.Hour
+	defaultLoginLockoutBase  = time.Second * 30
+	defaultLoginLockoutMax   = time.Hour
+	defaultRateLimits        = "login=30/1m,register=10/1h,magic-link=5/15m,password-reset=5/15m,mfa=10/15m,connections=120/1m,invites=30/1h"
+	defaultPasswordMinLength = 8
+	defaultPasswordMaxLength = 128
+	defaultPasswordMinScore  = 2
+	defaultPasswordResetURL  = "http://localhost:3000/reset-password"
+	defaultPasswordResetTTL  = time.Minute * 30
+	defaultLoginAlertURL     = "http://localhost:3000/not-me"
+	defaultLoginAlertTTL     = time.Hour * 24 * 7
+	defaultMaxTravelSpeed    = 1000
+	defaultChallengeProvider = "pow"
+	defaultChallengeBits     = 20
+	defaultChallengeFailures = 3
+	defaultChallengeRegs     = 3
+	defaultChallengeWindow   = time.Hour
+	defaultHTTPAddr          = ":3000"
+	defaultTLSReload         = time.Minute
+	defaultMutualTTL         = time.Minute * 5
+	defaultCounterReconcile  = time.Hour
+	defaultSuggestionsEvery  = time.Hour * 6
+	defaultSuggestionsTTL    = time.Hour * 12
+	defaultSecretsDir        = "/run/secrets"
+	defaultVaultMount        = "secret"
+	secretsTimeout           = time.Second * 10
+)
+
+var defaultWebAuthnRPOrigins = []string{"http://localhost:3000"}
+
+var defaultReservedUsernames = []string{
+	"admin", "administrator", "root", "system", "support", "help",
+	"moderator", "mod", "staff", "security", "official", "forumz",
+}
+
 func EnvNotSetError(env string) error {
 	return fmt.Errorf("%s environment variable not set", env)
 }
 
+func EnvInvalidError(env string, err error) error {
+	return fmt.Errorf("%s environment variable is invalid: %w", env, err)
+}
+
+// secretEnv holds the secrets that environment variables reference, by
+// variable. They are kept in memory rather than written back to the
+// environment, where child processes and crash dumps would see them.
+var secretEnv = map[string]string{}
+
+// lookupEnv is os.LookupEnv with secret references replaced by their secret.
+func lookupEnv(env string) (string, bool) {
+	if val, ok := secretEnv[env]; ok {
+		return val, true
+	}
+	return os.LookupEnv(env)
+}
+
+// lookupEnvString returns the value of env, or def when it is not set.
+func lookupEnvString(env, def string) string {
+	val, ok := lookupEnv(env)
+	if !ok {
+		return def
+	}
+	return val
+}
+
+// lookupEnvInt returns the integer value of env, or def when it is not set.
+func lookupEnvInt(env string, def int) (int, error) {
+	val, ok := lookupEnv(env)
+	if !ok {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, EnvInvalidError(env, err)
+	}
+	return i, nil
+}
+
+// lookupEnvBool returns the boolean value of env, or def when it is not set.
+func lookupEnvBool(env string, def bool) (bool, error) {
+	val, ok := lookupEnv(env)
+	if !ok {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, EnvInvalidError(env, err)
+	}
+	return b, nil
+}
+
+// lookupEnvDuration parses env with time.ParseDuration, or returns def when
+// it is not set.
+func lookupEnvDuration(env string, def time.Duration) (time.Duration, error) {
+	val, ok := lookupEnv(env)
+	if !ok {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, EnvInvalidError(env, err)
+	}
+	return d, nil
+}
+
+// lookupEnvList splits a comma separated env into its trimmed, non-empty
+// items, or returns def when it is not set.
+func lookupEnvList(env string, def []string) []string {
+	val, ok := lookupEnv(env)
+	if !ok {
+		return def
+	}
+
+	var items []string
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func NewConfig() (*Config, error) {
-	dbDSN, ok := os.LookupEnv(envDatabaseDSN)
+	resolved, err := resolveSecretEnv()
+	if err != nil {
+		return nil, err
+	}
+	secretEnv = resolved
+
+	dbDSN, ok := lookupEnv(envDatabaseDSN)
 	if !ok {
 		return nil, EnvNotSetError(envDatabaseDSN)
 	}
 
-	p12Cert, ok := os.LookupEnv(envP12Certificate)
+	p12Cert, ok := lookupEnv(envP12Certificate)
 	if !ok {
 		return nil, EnvNotSetError(envP12Certificate)
 	}
 
-	publicKey, ok := os.LookupEnv(envPublicKey)
+	publicKey, ok := lookupEnv(envPublicKey)
 	if !ok {
 		return nil, EnvNotSetError(envPublicKey)
 	}
 
-	certPassword, ok := os.LookupEnv(envCertPassword)
+	certPassword, ok := lookupEnv(envCertPassword)
 	if !ok {
 		return nil, EnvNotSetError(envCertPassword)
 	}
 
-	kafkaProducer, ok := os.LookupEnv(envKafkaProducer)
+	kafkaProducer, ok := lookupEnv(envKafkaProducer)
 	if !ok {
 		return nil, EnvNotSetError(envKafkaProducer)
 	}
 
-	kafkaConsumer, ok := os.LookupEnv(envKafkaConsumer)
+	kafkaConsumer, ok := lookupEnv(envKafkaConsumer)
 	if !ok {
 		return nil, EnvNotSetError(envKafkaConsumer)
 	}
 
-	redisServer, ok := os.LookupEnv(envRedisServer)
+	redisServer, ok := lookupEnv(envRedisServer)
 	if !ok {
 		return nil, EnvNotSetError(envRedisServer)
 	}
 
+	minimumAge, err := lookupEnvInt(envMinimumAge, defaultMinimumAge)
+	if err != nil {
+		return nil, err
+	}
+
+	usernameMinLength, err := lookupEnvInt(envUsernameMinLength, defaultUsernameMinLength)
+	if err != nil {
+		return nil, err
+	}
+
+	usernameMaxLength, err := lookupEnvInt(envUsernameMaxLength, defaultUsernameMaxLength)
+	if err != nil {
+		return nil, err
+	}
+	if usernameMaxLength < 1 || usernameMaxLength > maxUsernameLength {
+		return nil, EnvInvalidError(envUsernameMaxLength, fmt.Errorf("must be between 1 and %d", maxUsernameLength))
+	}
+	if usernameMinLength < 1 || usernameMinLength > usernameMaxLength {
+		return nil, EnvInvalidError(envUsernameMinLength, fmt.Errorf("must be between 1 and %d", usernameMaxLength))
+	}
+
+	blockDisposableEmails, err := lookupEnvBool(envBlockDisposableEmails, true)
+	if err != nil {
+		return nil, err
+	}
+
+	inviteOnly, err := lookupEnvBool(envInviteOnly, false)
+	if err != nil {
+		return nil, err
+	}
+
+	inviteAutoConnect, err := lookupEnvBool(envInviteAutoConnect, false)
+	if err != nil {
+		return nil, err
+	}
+
+	connectionRequests, err := lookupEnvBool(envConnectionRequests, false)
+	if err != nil {
+		return nil, err
+	}
+
+	mutualConnectionsTTL, err := lookupEnvDuration(envMutualConnectionsTTL, defaultMutualTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	counterReconcileInterval, err := lookupEnvDuration(envCounterReconcile, defaultCounterReconcile)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestionsInterval, err := lookupEnvDuration(envSuggestionsInterval, defaultSuggestionsEvery)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestionsTTL, err := lookupEnvDuration(envSuggestionsTTL, defaultSuggestionsTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	magicLinkTTL, err := lookupEnvDuration(envMagicLinkTTL, defaultMagicLinkTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	loginMaxAccountFailures, err := lookupEnvInt(envLoginMaxAccountFails, defaultLoginMaxAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	loginMaxIPFailures, err := lookupEnvInt(envLoginMaxIPFails, defaultLoginMaxIP)
+	if err != nil {
+		return nil, err
+	}
+
+	loginFailureWindow, err := lookupEnvDuration(envLoginFailureWindow, defaultLoginWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	loginLockoutBase, err := lookupEnvDuration(envLoginLockoutBase, defaultLoginLockoutBase)
+	if err != nil {
+		return nil, err
+	}
+
+	loginLockoutMax, err := lookupEnvDuration(envLoginLockoutMax, defaultLoginLockoutMax)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimits, err := ParseRateLimits(defaultRateLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	// Routes missing from the environment keep their default limit.
+	overrides, err := ParseRateLimits(lookupEnvString(envRateLimits, ""))
+	if err != nil {
+		return nil, EnvInvalidError(envRateLimits, err)
+	}
+	for name, limit := range overrides {
+		rateLimits[name] = limit
+	}
+
+	passwordMinLength, err := lookupEnvInt(envPasswordMinLength, defaultPasswordMinLength)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordMaxLength, err := lookupEnvInt(envPasswordMaxLength, defaultPasswordMaxLength)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordMinScore, err := lookupEnvInt(envPasswordMinScore, defaultPasswordMinScore)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordResetTTL, err := lookupEnvDuration(envPasswordResetTTL, defaultPasswordResetTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordHistory, err := lookupEnvInt(envPasswordHistory, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	argon2Memory, err := lookupEnvInt(envArgon2Memory, int(Memory))
+	if err != nil {
+		return nil, err
+	}
+
+	argon2Iterations, err := lookupEnvInt(envArgon2Iterations, int(Iterations))
+	if err != nil {
+		return nil, err
+	}
+	if argon2Iterations < 1 {
+		return nil, EnvInvalidError(envArgon2Iterations, errors.New("must be at least 1"))
+	}
+
+	argon2Parallelism, err := lookupEnvInt(envArgon2Parallelism, int(Paralelism))
+	if err != nil {
+		return nil, err
+	}
+	if argon2Parallelism < 1 || argon2Parallelism > math.MaxUint8 {
+		return nil, EnvInvalidError(envArgon2Parallelism, fmt.Errorf("must be between 1 and %d", math.MaxUint8))
+	}
+
+	// argon2 needs at least 8 KiB per lane.
+	if argon2Memory < 8*argon2Parallelism || int64(argon2Memory) > math.MaxUint32 {
+		return nil, EnvInvalidError(envArgon2Memory, fmt.Errorf("must be at least %d KiB", 8*argon2Parallelism))
+	}
+
+	passwordHashParams := DefaultParams
+	passwordHashParams.Memory = uint32(argon2Memory)
+	passwordHashParams.Iterations = uint32(argon2Iterations)
+	passwordHashParams.Paralelism = uint8(argon2Parallelism)
+
+	pepperEntries := lookupEnvList(envPasswordPeppers, nil)
+	if path := lookupEnvString(envPasswordPepperFile, ""); path != "" {
+		fileEntries, err := ReadPepperFile(path)
+		if err != nil {
+			return nil, EnvInvalidError(envPasswordPepperFile, err)
+		}
+		pepperEntries = append(pepperEntries, fileEntries...)
+	}
+
+	passwordPeppers, err := ParsePeppers(pepperEntries)
+	if err != nil {
+		return nil, EnvInvalidError(envPasswordPeppers, err)
+	}
+
+	// New hashes use the newest pepper unless told otherwise.
+	latestPepper := 0
+	for version := range passwordPeppers {
+		latestPepper = max(latestPepper, version)
+	}
+
+	pepperVersion, err := lookupEnvInt(envPasswordPepperVersion, latestPepper)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := passwordPeppers[pepperVersion]; pepperVersion != 0 && !ok {
+		return nil, EnvInvalidError(envPasswordPepperVersion, fmt.Errorf("no pepper with version %d", pepperVersion))
+	}
+	passwordHashParams.PepperVersion = pepperVersion
+
+	uniformAuthResponses, err := lookupEnvBool(envUniformAuthResponses, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var adminIds []uuid.UUID
+	for _, id := range lookupEnvList(envAdminIds, nil) {
+		adminId, err := uuid.Parse(id)
+		if err != nil {
+			return nil, EnvInvalidError(envAdminIds, err)
+		}
+		adminIds = append(adminIds, adminId)
+	}
+
+	loginAlerts, err := lookupEnvBool(envLoginAlerts, true)
+	if err != nil {
+		return nil, err
+	}
+
+	loginAlertTTL, err := lookupEnvDuration(envLoginAlertTTL, defaultLoginAlertTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	loginMaxTravelSpeed, err := lookupEnvInt(envLoginMaxTravelSpeed, defaultMaxTravelSpeed)
+	if err != nil {
+		return nil, err
+	}
+
+	challengeDifficulty, err := lookupEnvInt(envChallengeDifficulty, defaultChallengeBits)
+	if err != nil {
+		return nil, err
+	}
+	if challengeDifficulty < 1 || challengeDifficulty > 32 {
+		return nil, EnvInvalidError(envChallengeDifficulty, errors.New("must be between 1 and 32"))
+	}
+
+	challengeAfterFailures, err := lookupEnvInt(envChallengeAfterFails, defaultChallengeFailures)
+	if err != nil {
+		return nil, err
+	}
+
+	challengeAfterRegistrations, err := lookupEnvInt(envChallengeAfterRegs, defaultChallengeRegs)
+	if err != nil {
+		return nil, err
+	}
+
+	challengeWindow, err := lookupEnvDuration(envChallengeWindow, defaultChallengeWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	challengeNewNetworks, err := lookupEnvBool(envChallengeNewNetworks, false)
+	if err != nil {
+		return nil, err
+	}
+
+	httpAddr := lookupEnvString(envHTTPAddr, defaultHTTPAddr)
+	tlsAddr := lookupEnvString(envTLSAddr, "")
+	if httpAddr == "" && tlsAddr == "" {
+		return nil, fmt.Errorf("%s and %s are both empty, nothing would be served", envHTTPAddr, envTLSAddr)
+	}
+
+	tlsReloadInterval, err := lookupEnvDuration(envTLSReloadInterval, defaultTLSReload)
+	if err != nil {
+		return nil, err
+	}
+
+	mtlsAddr := lookupEnvString(envMTLSAddr, "")
+	mtlsClientCAFile := lookupEnvString(envMTLSClientCAFile, "")
+	if mtlsAddr != "" && mtlsClientCAFile == "" {
+		return nil, EnvNotSetError(envMTLSClientCAFile)
+	}
+
+	mtlsPrincipals := make(map[string]string)
+	for _, entry := range lookupEnvList(envMTLSPrincipals, nil) {
+		commonName, principal, ok := strings.Cut(entry, ":")
+		if !ok || commonName == "" || principal == "" {
+			return nil, EnvInvalidError(envMTLSPrincipals, fmt.Errorf("invalid entry %q, expected common name:principal", entry))
+		}
+		mtlsPrincipals[commonName] = principal
+	}
+	if mtlsAddr != "" && len(mtlsPrincipals) == 0 {
+		return nil, EnvNotSetError(envMTLSPrincipals)
+	}
+
+	trustedProxies := lookupEnvList(envTrustedProxies, nil)
+	for _, proxy := range trustedProxies {
+		_, _, err = net.ParseCIDR(proxy)
+		if err != nil && net.ParseIP(proxy) == nil {
+			return nil, EnvInvalidError(envTrustedProxies, fmt.Errorf("invalid entry %q, expected an IP address or CIDR range", proxy))
+		}
+	}
+
 	return &Config{
 		DatabaseDSN:         dbDSN,
 		P12Certificate:      p12Cert,
@@ -73,5 +662,79 @@ func NewConfig() (*Config, error) {
 		KafkaConsumerServer: kafkaConsumer,
 		KafkaProducerServer: kafkaProducer,
 		RedisServer:         redisServer,
+
+		MinimumAge:            minimumAge,
+		ReservedUsernames:     lookupEnvList(envReservedUsernames, defaultReservedUsernames),
+		UsernameMinLength:     usernameMinLength,
+		UsernameMaxLength:     usernameMaxLength,
+		UsernameCharset:       lookupEnvString(envUsernameCharset, defaultUsernameCharset),
+		BlockedEmailDomains:   lookupEnvList(envBlockedEmailDomains, nil),
+		BlockDisposableEmails: blockDisposableEmails,
+
+		InviteOnly:        inviteOnly,
+		InviteAutoConnect: inviteAutoConnect,
+
+		ConnectionRequests:       connectionRequests,
+		MutualConnectionsTTL:     mutualConnectionsTTL,
+		CounterReconcileInterval: counterReconcileInterval,
+		SuggestionsInterval:      suggestionsInterval,
+		SuggestionsTTL:           suggestionsTTL,
+
+		MFAIssuer: lookupEnvString(envMFAIssuer, defaultMFAIssuer),
+
+		WebAuthnRPID:          lookupEnvString(envWebAuthnRPID, defaultWebAuthnRPID),
+		WebAuthnRPDisplayName: lookupEnvString(envWebAuthnRPDisplayName, defaultMFAIssuer),
+		WebAuthnRPOrigins:     lookupEnvList(envWebAuthnRPOrigins, defaultWebAuthnRPOrigins),
+
+		MagicLinkURL: lookupEnvString(envMagicLinkURL, defaultMagicLinkURL),
+		MagicLinkTTL: magicLinkTTL,
+
+		LoginMaxAccountFailures: loginMaxAccountFailures,
+		LoginMaxIPFailures:      loginMaxIPFailures,
+		LoginFailureWindow:      loginFailureWindow,
+		LoginLockoutBase:        loginLockoutBase,
+		LoginLockoutMax:         loginLockoutMax,
+
+		RateLimits: rateLimits,
+
+		PasswordMinLength:  passwordMinLength,
+		PasswordMaxLength:  passwordMaxLength,
+		PasswordMinScore:   passwordMinScore,
+		PasswordBreachFile: lookupEnvString(envPasswordBreachFile, ""),
+		PasswordResetURL:   lookupEnvString(envPasswordResetURL, defaultPasswordResetURL),
+		PasswordResetTTL:   passwordResetTTL,
+		PasswordHistory:    passwordHistory,
+
+		PasswordHashParams: passwordHashParams,
+		PasswordPeppers:    passwordPeppers,
+
+		UniformAuthResponses: uniformAuthResponses,
+
+		AdminIds: adminIds,
+
+		LoginAlerts:         loginAlerts,
+		GeoIPFile:           lookupEnvString(envGeoIPFile, ""),
+		LoginAlertURL:       lookupEnvString(envLoginAlertURL, defaultLoginAlertURL),
+		LoginAlertTTL:       loginAlertTTL,
+		LoginMaxTravelSpeed: loginMaxTravelSpeed,
+
+		ChallengeProvider:           lookupEnvString(envChallengeProvider, defaultChallengeProvider),
+		ChallengeSiteKey:            lookupEnvString(envChallengeSiteKey, ""),
+		ChallengeSecret:             lookupEnvString(envChallengeSecret, ""),
+		ChallengeDifficulty:         challengeDifficulty,
+		ChallengeAfterFailures:      challengeAfterFailures,
+		ChallengeAfterRegistrations: challengeAfterRegistrations,
+		ChallengeWindow:             challengeWindow,
+		ChallengeNewNetworks:        challengeNewNetworks,
+
+		HTTPAddr:          httpAddr,
+		TLSAddr:           tlsAddr,
+		TLSCertFile:       lookupEnvString(envTLSCertFile, ""),
+		TLSKeyFile:        lookupEnvString(envTLSKeyFile, ""),
+		TLSReloadInterval: tlsReloadInterval,
+		MTLSAddr:          mtlsAddr,
+		MTLSClientCAFile:  mtlsClientCAFile,
+		MTLSPrincipals:    mtlsPrincipals,
+		TrustedProxies:    trustedProxies,
 	}, nil
 }
@@ -0,0 +1,154 @@
+package pkg
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GeoLocation is the position of an IP address as far as a GeoIP database
+// knows it. Latitude and Longitude are only meaningful when HasCoordinates
+// is set.
+type GeoLocation struct {
+	Country        string
+	Latitude       float64
+	Longitude      float64
+	HasCoordinates bool
+}
+
+type geoRange struct {
+	start    netip.Addr
+	end      netip.Addr
+	location GeoLocation
+}
+
+// GeoIPFile resolves IP addresses against a local CSV database of address
+// ranges, so no request leaves the host. Each line holds
+// start_ip,end_ip,country or start_ip,end_ip,country,latitude,longitude;
+// the DB-IP "IP to City Lite" layout
+// (start,end,continent,country,region,city,latitude,longitude) is accepted
+// too. The ranges are loaded into memory once.
+type GeoIPFile struct {
+	ranges []geoRange
+}
+
+// Locate returns the location of ip, or false when no range contains it.
+func (f *GeoIPFile) Locate(ip netip.Addr) (*GeoLocation, bool) {
+	ip = ip.Unmap()
+	i := sort.Search(len(f.ranges), func(i int) bool {
+		return f.ranges[i].start.Compare(ip) > 0
+	})
+	if i == 0 {
+		return nil, false
+	}
+
+	r := f.ranges[i-1]
+	if r.end.Compare(ip) < 0 {
+		return nil, false
+	}
+
+	location := r.location
+	return &location, true
+}
+
+// DistanceKm is the great circle distance between two locations.
+func DistanceKm(a, b *GeoLocation) float64 {
+	const earthRadiusKm = 6371
+
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func parseGeoRange(record []string) (geoRange, error) {
+	var r geoRange
+
+	countryCol, latCol := 2, 3
+	switch len(record) {
+	case 3, 5:
+	case 8:
+		countryCol, latCol = 3, 6
+	default:
+		return r, fmt.Errorf("unexpected number of columns %d", len(record))
+	}
+
+	var err error
+	r.start, err = netip.ParseAddr(strings.TrimSpace(record[0]))
+	if err != nil {
+		return r, err
+	}
+	r.end, err = netip.ParseAddr(strings.TrimSpace(record[1]))
+	if err != nil {
+		return r, err
+	}
+	r.start, r.end = r.start.Unmap(), r.end.Unmap()
+	if r.start.Is4() != r.end.Is4() || r.end.Less(r.start) {
+		return r, errors.New("invalid address range")
+	}
+
+	r.location.Country = strings.ToUpper(strings.TrimSpace(record[countryCol]))
+	if len(record) > 3 {
+		r.location.Latitude, err = strconv.ParseFloat(strings.TrimSpace(record[latCol]), 64)
+		if err != nil {
+			return r, err
+		}
+		r.location.Longitude, err = strconv.ParseFloat(strings.TrimSpace(record[latCol+1]), 64)
+		if err != nil {
+			return r, err
+		}
+		r.location.HasCoordinates = true
+	}
+
+	return r, nil
+}
+
+func NewGeoIPFile(path string) (*GeoIPFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	var ranges []geoRange
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("geoip: %s: %w", path, err)
+		}
+
+		r, err := parseGeoRange(record)
+		if err != nil {
+			// Allow a header row.
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("geoip: %s line %d: %w", path, line, err)
+		}
+		ranges = append(ranges, r)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start.Less(ranges[j].start)
+	})
+
+	return &GeoIPFile{
+		ranges: ranges,
+	}, nil
+}
@@ -7,9 +7,12 @@ import (
 	"errors"
 	"fmt"
 	"golang.org/x/crypto/argon2"
+	"math"
+	"strconv"
 	"strings"
 )
 
+// Default hashing parameters, used unless the configuration overrides them.
 const (
 	Iterations uint32 = 3
 	Memory     uint32 = 64 * 1024 // KibiBytes
@@ -18,12 +21,36 @@ const (
 	SaltLength uint32 = 16
 )
 
+const argon2idPrefix = "$argon2id$"
+
 type Params struct {
 	Memory     uint32
 	Iterations uint32
 	Paralelism uint8
 	KeyLength  uint32
 	SaltLength uint32
+	// PepperVersion selects the registered pepper mixed into the password,
+	// 0 for none. It is stored in the hash as ",pepper=<version>" after the
+	// cost parameters.
+	PepperVersion int
+}
+
+var DefaultParams = Params{
+	Memory:     Memory,
+	Iterations: Iterations,
+	Paralelism: Paralelism,
+	KeyLength:  KeyLength,
+	SaltLength: SaltLength,
+}
+
+// Weaker reports whether hashes made with p are cheaper to compute than
+// hashes made with target in any dimension.
+func (p *Params) Weaker(target Params) bool {
+	return p.Memory < target.Memory ||
+		p.Iterations < target.Iterations ||
+		p.Paralelism < target.Paralelism ||
+		p.KeyLength < target.KeyLength ||
+		p.SaltLength < target.SaltLength
 }
 
 // Hash Generation steps
@@ -43,21 +70,56 @@ type Params struct {
 // 3. Check if the contents are identical
 
 func HashPassword(rawPassword string) (string, error) {
-	salt, err := generateRandomBytes(SaltLength)
+	return HashPasswordWithParams(rawPassword, DefaultParams)
+}
+
+func HashPasswordWithParams(rawPassword string, p Params) (string, error) {
+	salt, err := generateRandomBytes(p.SaltLength)
+	if err != nil {
+		return "", err
+	}
+
+	input, err := applyPepper(rawPassword, p.PepperVersion)
 	if err != nil {
 		return "", err
 	}
 
-	hash := argon2.IDKey([]byte(rawPassword), []byte(salt), Iterations, Memory, Paralelism, KeyLength)
+	hash := argon2.IDKey(input, []byte(salt), p.Iterations, p.Memory, p.Paralelism, p.KeyLength)
 	b64Salt := base64.RawStdEncoding.EncodeToString(salt)
 	b64Hash := base64.RawStdEncoding.EncodeToString(hash)
 
-	encodedHash := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, Memory, Iterations, Paralelism, b64Salt, b64Hash)
+	params := fmt.Sprintf("m=%d,t=%d,p=%d", p.Memory, p.Iterations, p.Paralelism)
+	if p.PepperVersion > 0 {
+		params += fmt.Sprintf(",pepper=%d", p.PepperVersion)
+	}
+
+	encodedHash := fmt.Sprintf("$argon2id$v=%d$%s$%s$%s", argon2.Version, params, b64Salt, b64Hash)
 
 	return encodedHash, nil
 }
 
+// NeedsRehash reports whether encodedHash is not argon2id, was created with
+// parameters weaker than p or with another pepper. Malformed argon2id hashes
+// are left alone.
+func NeedsRehash(encodedHash string, p Params) bool {
+	if !strings.HasPrefix(encodedHash, argon2idPrefix) {
+		return true
+	}
+
+	stored, _, _, err := DecodeHash(encodedHash)
+	if err != nil {
+		return false
+	}
+	return stored.Weaker(p) || stored.PepperVersion != p.PepperVersion
+}
+
+// ComparePasswordAndHash checks password against an argon2id hash, or against
+// a hash in any format with a registered PasswordVerifier.
 func ComparePasswordAndHash(password, encodedHash string) (match bool, err error) {
+	if !strings.HasPrefix(encodedHash, argon2idPrefix) {
+		return verifyLegacy(password, encodedHash)
+	}
+
 	// Extract the parameters, salt and derived key from the encoded password
 	// hash.
 	p, salt, hash, err := DecodeHash(encodedHash)
@@ -65,8 +127,13 @@ func ComparePasswordAndHash(password, encodedHash string) (match bool, err error
 		return false, err
 	}
 
+	input, err := applyPepper(password, p.PepperVersion)
+	if err != nil {
+		return false, err
+	}
+
 	// Derive the key from the other password using the same parameters.
-	otherHash := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Paralelism, p.KeyLength)
+	otherHash := argon2.IDKey(input, salt, p.Iterations, p.Memory, p.Paralelism, p.KeyLength)
 
 	// Check that the contents of the hashed passwords are identical
 	// subtle.ConstantTimeCompare() isto help prevent timing attacks.
@@ -92,7 +159,7 @@ func DecodeHash(encodedHash string) (p *Params, salt, hash []byte, err error) {
 		return p, nil, nil, errors.New("invalid hash version")
 	}
 	p = new(Params)
-	_, err = fmt.Sscanf(vals[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Paralelism)
+	err = decodeParams(vals[3], p)
 	if err != nil {
 		return p, nil, nil, err
 	}
@@ -108,12 +175,51 @@ func DecodeHash(encodedHash string) (p *Params, salt, hash []byte, err error) {
 	if err != nil {
 		return p, nil, nil, err
 	}
+	if len(hash) < minKeyLength {
+		return p, nil, nil, errors.New("invalid hash key length")
+	}
 
 	p.KeyLength = uint32(len(hash))
 
 	return p, salt, hash, nil
 }
 
+// decodeParams parses the "m=..,t=..,p=..[,pepper=..]" field of a hash.
+func decodeParams(field string, p *Params) error {
+	var memory, iterations, parallelism bool
+	for _, param := range strings.Split(field, ",") {
+		key, val, ok := strings.Cut(param, "=")
+		n, err := strconv.ParseUint(val, 10, 32)
+		if !ok || err != nil {
+			return fmt.Errorf("invalid hash parameter %q", param)
+		}
+
+		switch key {
+		case "m":
+			p.Memory, memory = uint32(n), true
+		case "t":
+			p.Iterations, iterations = uint32(n), true
+		case "p":
+			if n > math.MaxUint8 {
+				return fmt.Errorf("invalid hash parameter %q", param)
+			}
+			p.Paralelism, parallelism = uint8(n), true
+		case "pepper":
+			p.PepperVersion = int(n)
+		default:
+			return fmt.Errorf("unknown hash parameter %q", key)
+		}
+	}
+
+	if !memory || !iterations || !parallelism {
+		return errors.New("missing hash parameters")
+	}
+	if p.Iterations < 1 || p.Paralelism < 1 {
+		return errors.New("invalid hash parameters")
+	}
+	return nil
+}
+
 func generateRandomBytes(n uint32) ([]byte, error) {
 	b := make([]byte, n)
 	_, err := rand.Read(b)
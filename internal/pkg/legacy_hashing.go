@@ -0,0 +1,159 @@
+package pkg
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"hash"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// minKeyLength is the shortest derived key a hash may store. Shorter keys,
+// empty ones in particular, would match almost any password.
+const minKeyLength = 16
+
+// PasswordVerifier checks a password against a hash in a format other than
+// argon2id, typically one imported from another system.
+type PasswordVerifier interface {
+	Verify(password, encodedHash string) (bool, error)
+}
+
+// VerifierFunc adapts a function to PasswordVerifier.
+type VerifierFunc func(password, encodedHash string) (bool, error)
+
+func (f VerifierFunc) Verify(password, encodedHash string) (bool, error) {
+	return f(password, encodedHash)
+}
+
+var (
+	verifiersMu sync.RWMutex
+	verifiers   = map[string]PasswordVerifier{}
+)
+
+// RegisterVerifier makes v responsible for hashes starting with prefix.
+// When several prefixes match a hash the longest one wins.
+func RegisterVerifier(prefix string, v PasswordVerifier) {
+	verifiersMu.Lock()
+	defer verifiersMu.Unlock()
+	verifiers[prefix] = v
+}
+
+func lookupVerifier(encodedHash string) (PasswordVerifier, bool) {
+	verifiersMu.RLock()
+	defer verifiersMu.RUnlock()
+
+	var match string
+	for prefix := range verifiers {
+		if strings.HasPrefix(encodedHash, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	if match == "" {
+		return nil, false
+	}
+	return verifiers[match], true
+}
+
+func verifyLegacy(password, encodedHash string) (bool, error) {
+	v, ok := lookupVerifier(encodedHash)
+	if !ok {
+		return false, ErrUnknownHashFormat
+	}
+	return v.Verify(password, encodedHash)
+}
+
+func init() {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		RegisterVerifier(prefix, VerifierFunc(verifyBcrypt))
+	}
+	RegisterVerifier("$scrypt$", VerifierFunc(verifyScrypt))
+	RegisterVerifier("pbkdf2_sha256$", pbkdf2Verifier(sha256.New))
+	RegisterVerifier("pbkdf2_sha1$", pbkdf2Verifier(sha1.New))
+}
+
+func verifyBcrypt(password, encodedHash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// verifyScrypt checks hashes in the passlib format
+// $scrypt$ln=<log2 N>,r=<r>,p=<p>$<salt>$<key>, where salt and key use
+// unpadded base64 with "." in place of "+".
+func verifyScrypt(password, encodedHash string) (bool, error) {
+	vals := strings.Split(encodedHash, "$")
+	if len(vals) != 5 {
+		return false, errors.New("invalid scrypt hash format")
+	}
+
+	var logN, r, p int
+	_, err := fmt.Sscanf(vals[2], "ln=%d,r=%d,p=%d", &logN, &r, &p)
+	if err != nil {
+		return false, err
+	}
+	if logN < 1 || logN > 30 || r < 1 || p < 1 {
+		return false, errors.New("invalid scrypt cost")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(strings.ReplaceAll(vals[3], ".", "+"))
+	if err != nil {
+		return false, err
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(strings.ReplaceAll(vals[4], ".", "+"))
+	if err != nil {
+		return false, err
+	}
+	if len(key) < minKeyLength {
+		return false, errors.New("invalid scrypt key length")
+	}
+
+	otherKey, err := scrypt.Key([]byte(password), salt, 1<<logN, r, p, len(key))
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+// pbkdf2Verifier checks hashes in the Django format
+// <algorithm>$<iterations>$<salt>$<base64 key>.
+func pbkdf2Verifier(h func() hash.Hash) PasswordVerifier {
+	return VerifierFunc(func(password, encodedHash string) (bool, error) {
+		vals := strings.Split(encodedHash, "$")
+		if len(vals) != 4 {
+			return false, errors.New("invalid pbkdf2 hash format")
+		}
+
+		iterations, err := strconv.Atoi(vals[1])
+		if err != nil || iterations < 1 {
+			return false, errors.New("invalid pbkdf2 iterations")
+		}
+
+		key, err := base64.StdEncoding.DecodeString(vals[3])
+		if err != nil {
+			return false, err
+		}
+		if len(key) < minKeyLength {
+			return false, errors.New("invalid pbkdf2 key length")
+		}
+
+		otherKey := pbkdf2.Key([]byte(password), []byte(vals[2]), iterations, len(key), h)
+		return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+	})
+}
@@ -0,0 +1,105 @@
+package pkg
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MinPepperLength is the shortest pepper key accepted, in bytes.
+const MinPepperLength = 16
+
+var ErrUnknownPepper = errors.New("unknown password pepper version")
+
+var (
+	peppersMu sync.RWMutex
+	peppers   = map[int][]byte{}
+)
+
+// RegisterPepper makes key the pepper of version. Hashes record the version
+// they were peppered with, so retired keys must stay registered until no
+// hash uses them any more.
+func RegisterPepper(version int, key []byte) {
+	peppersMu.Lock()
+	defer peppersMu.Unlock()
+	peppers[version] = key
+}
+
+// applyPepper returns the argon2 input for password: HMAC-SHA256 of it keyed
+// with the pepper of version, or the password itself for version 0.
+func applyPepper(password string, version int) ([]byte, error) {
+	if version == 0 {
+		return []byte(password), nil
+	}
+
+	peppersMu.RLock()
+	key, ok := peppers[version]
+	peppersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownPepper, version)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	return mac.Sum(nil), nil
+}
+
+// ParsePeppers parses "version:base64 key" entries. Versions must be
+// positive and keys at least MinPepperLength bytes long.
+func ParsePeppers(entries []string) (map[int][]byte, error) {
+	keys := make(map[int][]byte)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		v, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, errors.New("invalid pepper, expected version:base64 key")
+		}
+
+		version, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid pepper version %q", v)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("pepper %d: %w", version, err)
+		}
+		if len(key) < MinPepperLength {
+			return nil, fmt.Errorf("pepper %d must be at least %d bytes", version, MinPepperLength)
+		}
+
+		if _, exists := keys[version]; exists {
+			return nil, fmt.Errorf("pepper %d is defined twice", version)
+		}
+		keys[version] = key
+	}
+	return keys, nil
+}
+
+// ReadPepperFile reads a pepper key file: one "version:base64 key" entry per
+// line, blank lines and lines starting with # are ignored.
+func ReadPepperFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entries = append(entries, scanner.Text())
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,102 @@
+package pkg
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// PwnedPasswordFile looks up passwords in a local copy of the Have I Been
+// Pwned password list: one upper case SHA-1 hash per line followed by
+// ":COUNT", sorted by hash. The file is searched in place, so it is never
+// loaded into memory and no request leaves the host.
+type PwnedPasswordFile struct {
+	path string
+}
+
+// Contains reports whether password appears in the list.
+func (f *PwnedPasswordFile) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	target := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	// Binary search over byte offsets; every probe is resolved to the first
+	// line starting at or after the offset.
+	lo, hi := int64(0), info.Size()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := lineAt(file, mid, info.Size())
+		if errors.Is(err, io.EOF) {
+			hi = mid
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+		switch strings.Compare(strings.ToUpper(hash), target) {
+		case 0:
+			return true, nil
+		case -1:
+			lo = start + int64(len(line))
+		default:
+			hi = mid
+		}
+	}
+	return false, nil
+}
+
+// lineAt returns the offset and contents, including the trailing newline, of
+// the first line starting at or after off.
+func lineAt(r io.ReaderAt, off, size int64) (int64, string, error) {
+	start := off
+	if off > 0 {
+		start = off - 1
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(r, start, size-start))
+	if off > 0 {
+		// Skip the remainder of the line that off falls into, unless off is
+		// already the first byte of a line.
+		skipped, err := reader.ReadString('\n')
+		if err != nil {
+			return 0, "", io.EOF
+		}
+		start += int64(len(skipped))
+	}
+
+	line, err := reader.ReadString('\n')
+	if line == "" {
+		if err == nil {
+			err = io.EOF
+		}
+		return 0, "", err
+	}
+	return start, line, nil
+}
+
+func NewPwnedPasswordFile(path string) (*PwnedPasswordFile, error) {
+	_, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PwnedPasswordFile{
+		path: path,
+	}, nil
+}
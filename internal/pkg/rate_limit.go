@@ -0,0 +1,64 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration
+}
+
+// RateLimiter counts requests per key over a window shared by every replica.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error)
+}
+
+// ParseRateLimits parses a comma separated list of name=limit/window pairs,
+// e.g. "login=30/1m,register=10/1h".
+func ParseRateLimits(s string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, spec, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit %q", item)
+		}
+
+		count, window, ok := strings.Cut(spec, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit %q", item)
+		}
+
+		limit, err := strconv.Atoi(count)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit %q: %w", item, err)
+		}
+
+		d, err := time.ParseDuration(window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit %q: %w", item, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid rate limit %q: window must be positive", item)
+		}
+
+		limits[strings.TrimSpace(name)] = RateLimit{Limit: limit, Window: d}
+	}
+	return limits, nil
+}
@@ -0,0 +1,264 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretScheme prefixes configuration values that name a secret instead of
+// holding it: secret://<provider>/<reference>.
+const SecretScheme = "secret://"
+
+const (
+	SecretProviderEnv   = "env"
+	SecretProviderFile  = "file"
+	SecretProviderVault = "vault"
+)
+
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretProvider looks up a secret by a reference whose meaning is up to the
+// provider.
+type SecretProvider interface {
+	Secret(ctx context.Context, reference string) (string, error)
+}
+
+// EnvSecrets reads secrets from other environment variables, so that
+// secret://env/NAME takes the value of NAME.
+type EnvSecrets struct{}
+
+func (EnvSecrets) Secret(_ context.Context, reference string) (string, error) {
+	value, ok := os.LookupEnv(reference)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s", ErrSecretNotFound, reference)
+	}
+	return value, nil
+}
+
+// FileSecrets reads secrets from files below a directory, the way Docker and
+// Kubernetes mount them: secret://file/db-password reads <dir>/db-password.
+// A trailing line break is dropped.
+type FileSecrets struct {
+	dir string
+}
+
+func (s *FileSecrets) Secret(_ context.Context, reference string) (string, error) {
+	if !filepath.IsLocal(reference) {
+		return "", fmt.Errorf("secret file %q is outside %s", reference, s.dir)
+	}
+
+	content, err := os.ReadFile(filepath.Join(s.dir, reference))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: file %s", ErrSecretNotFound, reference)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func NewFileSecrets(dir string) *FileSecrets {
+	return &FileSecrets{
+		dir: dir,
+	}
+}
+
+// VaultSecrets reads secrets from the KV version 2 engine of a HashiCorp
+// Vault compatible server: secret://vault/auth/database#password is the
+// password key of the auth/database secret. Secrets are read once and kept,
+// since several keys usually come from the same path.
+type VaultSecrets struct {
+	addr      string
+	token     string
+	mount     string
+	namespace string
+	client    *http.Client
+
+	mu    sync.Mutex
+	cache map[string]map[string]any
+}
+
+type vaultResponse struct {
+	Data struct {
+		Data map[string]any `json:"data"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func (s *VaultSecrets) Secret(ctx context.Context, reference string) (string, error) {
+	path, key, ok := strings.Cut(reference, "#")
+	if !ok || path == "" || key == "" {
+		return "", fmt.Errorf("vault reference %q, expected path#key", reference)
+	}
+
+	data, err := s.read(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("%w: key %s of vault secret %s", ErrSecretNotFound, key, path)
+	}
+	if str, ok := value.(string); ok {
+		return str, nil
+	}
+
+	// Numbers and booleans are handed out the way they are written in JSON.
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+func (s *VaultSecrets) read(ctx context.Context, path string) (map[string]any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if data, ok := s.cache[path]; ok {
+		return data, nil
+	}
+
+	endpoint, err := url.JoinPath(s.addr, "v1", s.mount, "data", path)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+	if s.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.namespace)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: read %s: %w", path, err)
+	}
+	defer res.Body.Close()
+
+	var body vaultResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil && res.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("vault: read %s: %w", path, err)
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: vault secret %s", ErrSecretNotFound, path)
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("vault: read %s: status %d %s", path, res.StatusCode, strings.Join(body.Errors, "; "))
+	}
+
+	s.cache[path] = body.Data.Data
+	return body.Data.Data, nil
+}
+
+func NewVaultSecrets(addr, token, mount, namespace string, timeout time.Duration) *VaultSecrets {
+	return &VaultSecrets{
+		addr:      addr,
+		token:     token,
+		mount:     mount,
+		namespace: namespace,
+		client:    &http.Client{Timeout: timeout},
+		cache:     make(map[string]map[string]any),
+	}
+}
+
+// SecretResolver dispatches secret references to the provider they name.
+type SecretResolver struct {
+	providers map[string]SecretProvider
+}
+
+// IsSecretReference reports whether value names a secret.
+func IsSecretReference(value string) bool {
+	return strings.HasPrefix(value, SecretScheme)
+}
+
+// Register makes provider answer references of the form
+// secret://<name>/<reference>.
+func (r *SecretResolver) Register(name string, provider SecretProvider) {
+	r.providers[name] = provider
+}
+
+// Resolve returns the secret value names, or value itself when it is not a
+// secret reference.
+func (r *SecretResolver) Resolve(ctx context.Context, value string) (string, error) {
+	if !IsSecretReference(value) {
+		return value, nil
+	}
+
+	name, reference, ok := strings.Cut(strings.TrimPrefix(value, SecretScheme), "/")
+	if !ok || reference == "" {
+		return "", fmt.Errorf("invalid secret reference %q, expected %s<provider>/<reference>", value, SecretScheme)
+	}
+
+	provider, ok := r.providers[name]
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider %q", name)
+	}
+	return provider.Secret(ctx, reference)
+}
+
+func NewSecretResolver() *SecretResolver {
+	return &SecretResolver{
+		providers: make(map[string]SecretProvider),
+	}
+}
+
+// resolveSecretEnv resolves every AUTH_SERVICE_ environment variable holding
+// a secret reference and returns the secrets by variable, so the rest of
+// NewConfig reads secrets like any other value. The Vault token may itself
+// reference an env or file secret.
+func resolveSecretEnv() (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretsTimeout)
+	defer cancel()
+
+	resolver := NewSecretResolver()
+	resolver.Register(SecretProviderEnv, EnvSecrets{})
+	resolver.Register(SecretProviderFile, NewFileSecrets(lookupEnvString(envSecretsDir, defaultSecretsDir)))
+
+	if vaultAddr := lookupEnvString(envVaultAddr, ""); vaultAddr != "" {
+		vaultToken, err := resolver.Resolve(ctx, lookupEnvString(envVaultToken, ""))
+		if err != nil {
+			return nil, EnvInvalidError(envVaultToken, err)
+		}
+		if vaultToken == "" {
+			return nil, EnvNotSetError(envVaultToken)
+		}
+
+		resolver.Register(SecretProviderVault, NewVaultSecrets(
+			vaultAddr,
+			vaultToken,
+			lookupEnvString(envVaultMount, defaultVaultMount),
+			lookupEnvString(envVaultNamespace, ""),
+			secretsTimeout,
+		))
+	}
+
+	resolved := make(map[string]string)
+	for _, entry := range os.Environ() {
+		env, value, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(env, envPrefix) || env == envVaultToken || !IsSecretReference(value) {
+			continue
+		}
+
+		secret, err := resolver.Resolve(ctx, value)
+		if err != nil {
+			return nil, EnvInvalidError(env, err)
+		}
+		resolved[env] = secret
+	}
+	return resolved, nil
+}
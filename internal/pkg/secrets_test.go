@@ -0,0 +1,136 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// vaultStandIn answers KV version 2 reads of secrets the way Vault does.
+func vaultStandIn(t *testing.T, token, namespace string, secrets map[string]map[string]any) (*httptest.Server, *int) {
+	reads := new(int)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/secret/data/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		*reads++
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("X-Vault-Token") != token || r.Header.Get("X-Vault-Namespace") != namespace {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		data, ok := secrets[r.PathValue("path")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		writeJSON(t, w, map[string]any{"data": map[string]any{"data": data}})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, reads
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, v any) {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(b)
+}
+
+func TestVaultSecrets(t *testing.T) {
+	server, reads := vaultStandIn(t, "root", "team", map[string]map[string]any{
+		"auth/database": {"dsn": "postgres://auth", "port": 5432},
+	})
+	vault := NewVaultSecrets(server.URL, "root", "secret", "team", time.Second)
+	ctx := context.Background()
+
+	tests := []struct {
+		reference string
+		want      string
+		err       error
+	}{
+		{"auth/database#dsn", "postgres://auth", nil},
+		{"auth/database#port", "5432", nil},
+		{"auth/database#missing", "", ErrSecretNotFound},
+		{"auth/missing#dsn", "", ErrSecretNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reference, func(t *testing.T) {
+			got, err := vault.Secret(ctx, tt.reference)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Secret() error = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("Secret() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// auth/database is read once, auth/missing once.
+	if *reads != 2 {
+		t.Errorf("stand-in read %d times, want 2", *reads)
+	}
+
+	if _, err := vault.Secret(ctx, "auth/database"); err == nil {
+		t.Error("Secret() accepted a reference without a key")
+	}
+}
+
+func TestVaultSecretsDenied(t *testing.T) {
+	server, _ := vaultStandIn(t, "root", "", nil)
+	vault := NewVaultSecrets(server.URL, "wrong", "secret", "", time.Second)
+
+	_, err := vault.Secret(context.Background(), "auth/database#dsn")
+	if err == nil || errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("Secret() error = %v, want permission denied", err)
+	}
+}
+
+func TestResolveSecretEnv(t *testing.T) {
+	server, _ := vaultStandIn(t, "root", "", map[string]map[string]any{
+		"auth/database": {"dsn": "postgres://auth"},
+	})
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "vault-token"), []byte("root\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(envSecretsDir, dir)
+	t.Setenv(envVaultAddr, server.URL)
+	t.Setenv(envVaultToken, "secret://file/vault-token")
+	t.Setenv(envDatabaseDSN, "secret://vault/auth/database#dsn")
+	t.Setenv("REDIS_PASSWORD", "hunter2")
+	t.Setenv(envRedisServer, "secret://env/REDIS_PASSWORD")
+
+	resolved, err := resolveSecretEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resolved[envDatabaseDSN]; got != "postgres://auth" {
+		t.Errorf("%s = %q", envDatabaseDSN, got)
+	}
+	if got := resolved[envRedisServer]; got != "hunter2" {
+		t.Errorf("%s = %q", envRedisServer, got)
+	}
+	if got := os.Getenv(envDatabaseDSN); got != "secret://vault/auth/database#dsn" {
+		t.Errorf("secret leaked into the environment: %s = %q", envDatabaseDSN, got)
+	}
+}
+
+func TestFileSecretsOutsideDir(t *testing.T) {
+	files := NewFileSecrets(t.TempDir())
+	if _, err := files.Secret(context.Background(), "../etc/passwd"); err == nil {
+		t.Fatal("Secret() read a file outside its directory")
+	}
+}
@@ -0,0 +1,168 @@
+package pkg
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"software.sslmate.com/src/go-pkcs12"
+	"sync"
+	"time"
+)
+
+// CertificateReloader serves a TLS certificate from PEM files or a P12
+// keystore and reloads it when the files change, so renewed certificates are
+// picked up without a restart. Files are checked at most once per interval,
+// on the next handshake; when a changed certificate fails to load, onError
+// is told and the previous certificate stays in use.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+	p12File  string
+	password string
+	interval time.Duration
+	onError  func(error)
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	modTime     time.Time
+	checkedAt   time.Time
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	due := time.Since(r.checkedAt) >= r.interval
+	r.mu.RUnlock()
+
+	if due {
+		err := r.Reload(false)
+		if err != nil && r.onError != nil {
+			r.onError(err)
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.certificate, nil
+}
+
+// Reload loads the certificate again when its files changed since the last
+// load, or always when force is set.
+func (r *CertificateReloader) Reload(force bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checkedAt = time.Now()
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	if !force && r.certificate != nil && !modTime.After(r.modTime) {
+		return nil
+	}
+
+	certificate, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	r.certificate, r.modTime = certificate, modTime
+	return nil
+}
+
+func (r *CertificateReloader) files() []string {
+	if r.p12File != "" {
+		return []string{r.p12File}
+	}
+	return []string{r.certFile, r.keyFile}
+}
+
+func (r *CertificateReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *CertificateReloader) load() (*tls.Certificate, error) {
+	if r.p12File == "" {
+		certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: load %s: %w", r.certFile, err)
+		}
+		return &certificate, nil
+	}
+
+	content, err := os.ReadFile(r.p12File)
+	if err != nil {
+		return nil, err
+	}
+
+	key, leaf, chain, err := pkcs12.DecodeChain(content, r.password)
+	if err != nil {
+		return nil, fmt.Errorf("tls: decode %s: %w", r.p12File, err)
+	}
+
+	certificate := &tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	for _, ca := range chain {
+		certificate.Certificate = append(certificate.Certificate, ca.Raw)
+	}
+	return certificate, nil
+}
+
+// NewCertificateReloader loads the certificate from certFile and keyFile
+// when both are set, otherwise from the P12 keystore p12File.
+func NewCertificateReloader(certFile, keyFile, p12File, password string, interval time.Duration, onError func(error)) (*CertificateReloader, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("tls: certificate and key file must be set together")
+	}
+	if certFile == "" && p12File == "" {
+		return nil, errors.New("tls: no certificate configured")
+	}
+	if certFile != "" {
+		p12File = ""
+	}
+
+	reloader := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		p12File:  p12File,
+		password: password,
+		interval: interval,
+		onError:  onError,
+	}
+
+	err := reloader.Reload(true)
+	if err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// LoadCertPool reads a PEM bundle of CA certificates, used to verify client
+// certificates on mutual TLS listeners.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("tls: no certificates found in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,12 @@
+create table if not exists audit_event (
+    id bigserial primary key,
+    actor_id UUID,
+    subject_id UUID,
+    action varchar(64) not null,
+    ip varchar(64) not null default '',
+    user_agent varchar(512) not null default '',
+    request_id UUID,
+    outcome varchar(16) not null,
+    details jsonb,
+    datetime_created timestamp with time zone not null default current_timestamp
+);
@@ -0,0 +1 @@
+create index if not exists idx_audit_event_actor_id on audit_event (actor_id, id desc);
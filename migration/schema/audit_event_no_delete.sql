@@ -0,0 +1 @@
+create or replace rule audit_event_no_delete as on delete to audit_event do instead nothing;
@@ -0,0 +1 @@
+create or replace rule audit_event_no_update as on update to audit_event do instead nothing;
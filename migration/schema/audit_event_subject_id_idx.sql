@@ -0,0 +1 @@
+create index if not exists idx_audit_event_subject_id on audit_event (subject_id, action, id desc);
@@ -0,0 +1,8 @@
+create table if not exists block (
+    blocker_id UUID not null,
+    blocked_id UUID not null,
+    datetime_created timestamp not null default current_timestamp,
+    primary key (blocker_id, blocked_id),
+    constraint fk_person_block_blocker_id foreign key (blocker_id) references person(id),
+    constraint fk_person_block_blocked_id foreign key (blocked_id) references person(id)
+);
@@ -0,0 +1 @@
+create index if not exists block_blocked_id_idx on block(blocked_id);
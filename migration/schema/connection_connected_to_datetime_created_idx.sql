@@ -0,0 +1,2 @@
+create index if not exists connection_connected_to_datetime_created_idx on connection(connected_to, datetime_created desc, user_id desc)
+    where status = 'accepted';
@@ -0,0 +1 @@
+create index if not exists connection_connected_to_status_idx on connection(connected_to, status);
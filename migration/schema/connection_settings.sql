@@ -0,0 +1,6 @@
+create table if not exists connection_settings (
+    person_id UUID not null primary key,
+    auto_accept boolean not null default false,
+    last_modified timestamp with time zone not null default current_timestamp,
+    constraint fk_person_connection_settings_person_id foreign key (person_id) references person(id)
+);
@@ -0,0 +1,2 @@
+alter table connection add column if not exists status varchar(12) not null default 'accepted'
+    constraint connection_status_check check (status in ('pending', 'accepted', 'rejected'));
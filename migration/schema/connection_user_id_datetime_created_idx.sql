@@ -0,0 +1,2 @@
+create index if not exists connection_user_id_datetime_created_idx on connection(user_id, datetime_created desc, connected_to desc)
+    where status = 'accepted';
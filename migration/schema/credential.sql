@@ -0,0 +1,15 @@
+create table if not exists credential (
+    id bytea not null primary key,
+    person_id UUID not null,
+    name varchar(64) not null default '',
+    public_key bytea not null,
+    attestation_type varchar(32) not null,
+    aaguid bytea,
+    sign_count bigint not null default 0,
+    transports varchar(128) not null default '',
+    backup_eligible boolean not null default false,
+    backup_state boolean not null default false,
+    datetime_created timestamp not null default current_timestamp,
+    last_used timestamp,
+    constraint fk_person_credential_person_id foreign key (person_id) references person(id)
+);
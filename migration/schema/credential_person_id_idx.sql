@@ -0,0 +1 @@
+create index if not exists idx_credential_person_id on credential (person_id);
@@ -0,0 +1,10 @@
+create table if not exists invite (
+    code varchar(32) not null primary key,
+    created_by UUID not null,
+    max_uses integer not null default 1,
+    uses integer not null default 0,
+    expires_at timestamp with time zone not null,
+    datetime_created timestamp not null default current_timestamp,
+    constraint fk_person_invite_created_by foreign key (created_by) references person(id),
+    constraint chk_invite_uses check (uses >= 0 and uses <= max_uses)
+);
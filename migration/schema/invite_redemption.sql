@@ -0,0 +1,9 @@
+create table if not exists invite_redemption (
+    invitee UUID not null primary key,
+    code varchar(32) not null,
+    invited_by UUID not null,
+    datetime_created timestamp not null default current_timestamp,
+    constraint fk_invite_redemption_code foreign key (code) references invite(code),
+    constraint fk_person_invite_redemption_invited_by foreign key (invited_by) references person(id),
+    constraint fk_person_invite_redemption_invitee foreign key (invitee) references person(id)
+);
@@ -0,0 +1,13 @@
+create table if not exists known_device (
+    person_id UUID not null,
+    fingerprint varchar(64) not null,
+    user_agent varchar(512) not null,
+    ip_prefix varchar(64) not null,
+    country varchar(2) not null default '',
+    latitude double precision,
+    longitude double precision,
+    datetime_created timestamp not null default current_timestamp,
+    last_seen timestamp not null default current_timestamp,
+    primary key (person_id, fingerprint),
+    constraint fk_person_known_device_person_id foreign key (person_id) references person(id)
+);
@@ -0,0 +1,3 @@
+alter table known_device
+    alter column datetime_created type timestamp with time zone,
+    alter column last_seen type timestamp with time zone;
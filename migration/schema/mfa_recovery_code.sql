@@ -0,0 +1,8 @@
+create table if not exists mfa_recovery_code (
+    person_id UUID not null,
+    code_hash varchar(64) not null,
+    used_at timestamp,
+    datetime_created timestamp not null default current_timestamp,
+    primary key (person_id, code_hash),
+    constraint fk_person_mfa_recovery_code_person_id foreign key (person_id) references person(id)
+);
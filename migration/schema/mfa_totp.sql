@@ -0,0 +1,8 @@
+create table if not exists mfa_totp (
+    person_id UUID not null primary key,
+    secret varchar(64) not null,
+    confirmed boolean not null default false,
+    last_used_step bigint not null default 0,
+    datetime_created timestamp not null default current_timestamp,
+    constraint fk_person_mfa_totp_person_id foreign key (person_id) references person(id)
+);
@@ -0,0 +1,7 @@
+create table if not exists password_history (
+    id bigserial primary key,
+    person_id UUID not null,
+    password_hash varchar(256) not null,
+    datetime_created timestamp not null default current_timestamp,
+    constraint fk_person_password_history_person_id foreign key (person_id) references person(id)
+);
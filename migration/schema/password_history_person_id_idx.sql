@@ -0,0 +1 @@
+create index if not exists idx_password_history_person_id on password_history (person_id, id desc);
@@ -0,0 +1 @@
+alter table person add column if not exists invite_quota integer not null default 5;
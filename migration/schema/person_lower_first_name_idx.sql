@@ -0,0 +1 @@
+create index if not exists person_lower_first_name_idx on person(lower(first_name) text_pattern_ops);
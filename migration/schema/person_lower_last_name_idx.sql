@@ -0,0 +1 @@
+create index if not exists person_lower_last_name_idx on person(lower(last_name) text_pattern_ops);
@@ -0,0 +1 @@
+create index if not exists person_lower_name_idx on person(lower(first_name), lower(last_name), id);
@@ -0,0 +1,8 @@
+create table if not exists suggestion_dismissal (
+    person_id UUID not null,
+    suggested_id UUID not null,
+    datetime_created timestamp not null default current_timestamp,
+    primary key (person_id, suggested_id),
+    constraint fk_person_suggestion_dismissal_person_id foreign key (person_id) references person(id),
+    constraint fk_person_suggestion_dismissal_suggested_id foreign key (suggested_id) references person(id)
+);